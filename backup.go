@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultBackupDir = "mongo-move-backups" // Local directory archive backups are written to when not set in config
+
+// Where target documents are saved before a copy deletes them, if anywhere
+type backupMode int
+
+const (
+	backupNone       backupMode = iota // Target documents are deleted without a backup
+	backupCollection                   // Target documents are saved to a timestamped collection next to the target
+	backupArchive                      // Target documents are saved to a local BSON file
+)
+
+var backupModes = []backupMode{backupNone, backupCollection, backupArchive}
+
+func (b backupMode) String() string {
+	switch b {
+	case backupNone:
+		return "none"
+	case backupCollection:
+		return "collection"
+	case backupArchive:
+		return "archive"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse backup mode by name, an empty name is no backup
+func parseBackupMode(name string) (backupMode, error) {
+	if name == "" {
+		return backupNone, nil
+	}
+	for _, b := range backupModes {
+		if b.String() == name {
+			return b, nil
+		}
+	}
+
+	return backupNone, fmt.Errorf("unknown backup mode %q, expected none, collection or archive", name)
+}
+
+// Backup mode after this one, wrapping around to the first
+func (b backupMode) next() backupMode {
+	return backupModes[(int(b)+1)%len(backupModes)]
+}
+
+// Snapshot of a target collection taken before a copy emptied it
+type backupRecord struct {
+	Run        string    `json:"run"`  // Run the backup was taken in, backups of the last run are rolled back together
+	Host       string    `json:"host"` // Target server host
+	Database   string    `json:"database"`
+	Collection string    `json:"collection"` // Target collection that was backed up
+	Mode       string    `json:"mode"`
+	Backup     string    `json:"backup"` // Backup collection on the target server or path of the archive file
+	Documents  int64     `json:"documents"`
+	Created    time.Time `json:"created"`
+}
+
+// Id of the run started now, sortable by time and used in backup names
+func newRunID() string {
+	return time.Now().UTC().Format("20060102T150405Z")
+}
+
+// Set the directory archive backups are written to, keeping the default if it is not set
+func (s storage) withBackupDir(dir string) storage {
+	if dir != "" {
+		s.backupDir = dir
+	}
+	return s
+}
+
+// Save the documents of the target collection before a destructive copy deletes them.
+// A target is backed up once per run, so a copy started again keeps the snapshot of the original documents.
+func (s storage) backupTarget(ctx context.Context, tc *mongo.Collection, spec copySpec) error {
+	if spec.backup == backupNone {
+		return nil
+	}
+
+	record := backupRecord{
+		Run:        s.run,
+		Host:       serverHost(s.targetURI),
+		Database:   spec.targetDatabase,
+		Collection: spec.targetCollection,
+		Mode:       spec.backup.String(),
+		Created:    time.Now().UTC(),
+	}
+	if s.state.hasBackup(record) {
+		return nil
+	}
+
+	count, err := tc.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("failed to count target documents to back up: %w", err)
+	} else if count == 0 {
+		return nil
+	}
+	record.Documents = count
+
+	switch spec.backup {
+	case backupCollection:
+		record.Backup = fmt.Sprintf("%s.backup_%s", spec.targetCollection, s.run)
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{}}}, {{Key: "$out", Value: record.Backup}}}
+		cursor, err := tc.Aggregate(ctx, pipeline)
+		if err != nil {
+			return fmt.Errorf("failed to back up target collection: %w", err)
+		}
+		cursor.Close(context.Background())
+	case backupArchive:
+		record.Backup = filepath.Join(s.backupDir, s.run, spec.targetDatabase+"."+spec.targetCollection+".bson")
+		if err := writeArchive(ctx, tc, record.Backup); err != nil {
+			return fmt.Errorf("failed to back up target collection: %w", err)
+		}
+	}
+
+	return s.state.addBackup(record)
+}
+
+// Write every document of a collection to a file of concatenated BSON documents, as mongodump does
+func writeArchive(ctx context.Context, c *mongo.Collection, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	cursor, err := c.Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	// Only a complete archive replaces the file
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	for cursor.Next(ctx) {
+		if _, err := f.Write(cursor.Current); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Restore the target collections backed up in the last run that took backups, replacing their documents.
+// Restored backups are forgotten, so rolling back again restores the run before. The backup collections
+// and files are kept.
+func (s storage) rollback(ctx context.Context) ([]backupRecord, error) {
+	records := s.state.lastRunBackups()
+	if len(records) == 0 {
+		return nil, errors.New("no backups to roll back to")
+	}
+
+	host := serverHost(s.targetURI)
+	for _, r := range records {
+		if r.Host != host {
+			return nil, fmt.Errorf("backups of the last run were taken on %s, choose it as the target", r.Host)
+		}
+	}
+
+	client, release, err := s.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	for _, r := range records {
+		if err := s.restoreBackup(ctx, client.Database(r.Database), r); err != nil {
+			return nil, fmt.Errorf("failed to restore %s.%s: %w", r.Database, r.Collection, err)
+		}
+	}
+
+	return records, s.state.removeBackups(records[0].Run)
+}
+
+// Replace the documents of a backed up collection with the documents of its backup
+func (s storage) restoreBackup(ctx context.Context, db *mongo.Database, r backupRecord) error {
+	var next func() (bson.Raw, error)
+	switch r.Mode {
+	case backupCollection.String():
+		cursor, err := db.Collection(r.Backup).Find(ctx, bson.D{})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(context.Background())
+		next = func() (bson.Raw, error) {
+			if !cursor.Next(ctx) {
+				if err := cursor.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			return cursor.Current, nil
+		}
+	case backupArchive.String():
+		f, err := os.Open(r.Backup)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		next = func() (bson.Raw, error) {
+			return bson.NewFromIOReader(f)
+		}
+	default:
+		return fmt.Errorf("unknown backup mode %q", r.Mode)
+	}
+
+	tc := db.Collection(r.Collection)
+	if _, err := tc.DeleteMany(ctx, bson.D{}); err != nil {
+		return err
+	}
+
+	var docs []interface{}
+	for {
+		doc, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		// Cursor documents are only valid until the next document is read
+		docs = append(docs, bson.Raw(append([]byte{}, doc...)))
+		if len(docs) >= s.batchSize {
+			if _, err := tc.InsertMany(ctx, docs); err != nil {
+				return err
+			}
+			docs = docs[:0]
+		}
+	}
+	if len(docs) > 0 {
+		if _, err := tc.InsertMany(ctx, docs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
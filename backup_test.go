@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseBackupMode(t *testing.T) {
+	for _, b := range backupModes {
+		parsed, err := parseBackupMode(b.String())
+		if err != nil || parsed != b {
+			t.Errorf("expected %s to parse, got %v, %v", b, parsed, err)
+		}
+	}
+
+	if b, err := parseBackupMode(""); err != nil || b != backupNone {
+		t.Errorf("expected empty name to be no backup, got %v, %v", b, err)
+	}
+	if _, err := parseBackupMode("disk"); err == nil {
+		t.Error("expected error for unknown backup mode")
+	}
+}
+
+func TestBackupMode_Next(t *testing.T) {
+	if b := backupNone.next(); b != backupCollection {
+		t.Errorf("expected collection after none, got %s", b)
+	}
+	if b := backupArchive.next(); b != backupNone {
+		t.Errorf("expected none after archive, got %s", b)
+	}
+}
+
+func TestWithBackupDir(t *testing.T) {
+	s := newStorage("target", "source")
+	if s.backupDir != defaultBackupDir {
+		t.Errorf("expected default backup dir, got %q", s.backupDir)
+	}
+	if s.run == "" {
+		t.Error("expected storage to have a run id")
+	}
+
+	if s = s.withBackupDir(""); s.backupDir != defaultBackupDir {
+		t.Errorf("expected unset backup dir to keep the default, got %q", s.backupDir)
+	}
+	if s = s.withBackupDir("/var/backups"); s.backupDir != "/var/backups" {
+		t.Errorf("expected backup dir to be set, got %q", s.backupDir)
+	}
+}
+
+func TestBackupTarget_Skipped(t *testing.T) {
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage("mongodb://prod:27017", "source").withState(ss)
+	spec := copySpec{targetDatabase: "shop", targetCollection: "orders", mode: modeReplace}
+
+	// Neither case touches the target collection
+	if err := s.backupTarget(context.Background(), nil, spec); err != nil {
+		t.Errorf("expected no backup without a backup mode, got %v", err)
+	}
+
+	spec.backup = backupArchive
+	record := backupRecord{Run: s.run, Host: "prod:27017", Database: "shop", Collection: "orders"}
+	if err := ss.addBackup(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.backupTarget(context.Background(), nil, spec); err != nil {
+		t.Errorf("expected target backed up earlier in the run to be skipped, got %v", err)
+	}
+}
+
+func TestRollback_NoBackups(t *testing.T) {
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage("mongodb://prod:27017", "source").withState(ss)
+
+	if _, err := s.rollback(context.Background()); err == nil || err.Error() != "no backups to roll back to" {
+		t.Errorf("expected no backups error, got %v", err)
+	}
+}
+
+func TestRollback_OtherTarget(t *testing.T) {
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.addBackup(backupRecord{Run: "20261017T090000Z", Host: "prod:27017", Database: "shop", Collection: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage("mongodb://staging:27017", "source").withState(ss)
+
+	if _, err := s.rollback(context.Background()); err == nil || !strings.Contains(err.Error(), "prod:27017") {
+		t.Errorf("expected error naming the server the backups were taken on, got %v", err)
+	}
+	if len(ss.lastRunBackups()) != 1 {
+		t.Error("expected backups to be kept when they were not restored")
+	}
+}
+
+func TestUpdate_ToggleBackup(t *testing.T) {
+	m := model{keyBindings: keyModel{keys: keys}}
+	m.profileChoices.profilesChosen = true
+	m.databaseChoices.databasesChosen = true
+	m.databaseChoices.targetDatabaseChoice = "shop"
+	m.collectionChoices.altscreen = true
+	m.collectionChoices.copyTasks = []collectionCopyTask{{id: 1, state: taskPending, target: collection{name: "orders"}}}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	m = updated.(model)
+	if m.collectionChoices.backup != backupCollection {
+		t.Fatalf("expected backup mode to switch to collection, got %s", m.collectionChoices.backup)
+	}
+	if spec := m.copySpec(m.collectionChoices.copyTasks[0]); spec.backup != backupCollection {
+		t.Errorf("expected copy spec to take the backup mode, got %s", spec.backup)
+	}
+}
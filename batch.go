@@ -0,0 +1,61 @@
+package main
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultBatchSize  = 1000             // Documents written per bulk write when not set in config
+	defaultBatchBytes = 16 * 1024 * 1024 // Bytes written per bulk write when not set in config
+)
+
+// Buffer of pending writes that are flushed to the target collection as a single bulk write
+type writeBatch struct {
+	models   []mongo.WriteModel
+	size     int // Total size in bytes of the buffered documents
+	maxCount int // Maximum number of documents in a batch
+	maxBytes int // Maximum size in bytes of a batch
+}
+
+// Initialize new write batch, falling back to defaults for limits that are not set
+func newWriteBatch(maxCount int, maxBytes int) *writeBatch {
+	if maxCount <= 0 {
+		maxCount = defaultBatchSize
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultBatchBytes
+	}
+
+	return &writeBatch{
+		models:   make([]mongo.WriteModel, 0, maxCount),
+		maxCount: maxCount,
+		maxBytes: maxBytes,
+	}
+}
+
+// Check if a document of the given size can be added without going over the batch limits.
+// An empty batch always accepts a document so oversized documents are still written on their own.
+func (b *writeBatch) fits(size int) bool {
+	if len(b.models) == 0 {
+		return true
+	}
+
+	return len(b.models) < b.maxCount && b.size+size <= b.maxBytes
+}
+
+// Add write model for a document of the given size
+func (b *writeBatch) add(model mongo.WriteModel, size int) {
+	b.models = append(b.models, model)
+	b.size += size
+}
+
+// Number of buffered documents
+func (b *writeBatch) len() int {
+	return len(b.models)
+}
+
+// Empty the batch so it can be reused
+func (b *writeBatch) reset() {
+	b.models = b.models[:0]
+	b.size = 0
+}
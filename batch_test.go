@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestNewWriteBatch_Defaults(t *testing.T) {
+	b := newWriteBatch(0, -1)
+	if b.maxCount != defaultBatchSize {
+		t.Errorf("expected maxCount %d, got %d", defaultBatchSize, b.maxCount)
+	}
+	if b.maxBytes != defaultBatchBytes {
+		t.Errorf("expected maxBytes %d, got %d", defaultBatchBytes, b.maxBytes)
+	}
+}
+
+func TestWriteBatch_FitsByCount(t *testing.T) {
+	b := newWriteBatch(2, 1024)
+	b.add(mongo.NewInsertOneModel(), 10)
+	if !b.fits(10) {
+		t.Error("expected second document to fit")
+	}
+	b.add(mongo.NewInsertOneModel(), 10)
+	if b.fits(10) {
+		t.Error("expected batch to be full by count")
+	}
+}
+
+func TestWriteBatch_FitsByBytes(t *testing.T) {
+	b := newWriteBatch(100, 100)
+	b.add(mongo.NewInsertOneModel(), 60)
+	if b.fits(50) {
+		t.Error("expected batch to be full by size")
+	}
+	if !b.fits(40) {
+		t.Error("expected document to fit within size")
+	}
+}
+
+func TestWriteBatch_OversizedDocumentFitsEmptyBatch(t *testing.T) {
+	b := newWriteBatch(100, 100)
+	if !b.fits(500) {
+		t.Error("expected oversized document to fit an empty batch")
+	}
+}
+
+func TestWriteBatch_Reset(t *testing.T) {
+	b := newWriteBatch(10, 100)
+	b.add(mongo.NewInsertOneModel(), 60)
+	b.reset()
+	if b.len() != 0 || b.size != 0 {
+		t.Errorf("expected empty batch after reset, got len %d size %d", b.len(), b.size)
+	}
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/square/exit"
+)
+
+// Headless subcommand run from the command line instead of the terminal UI
+type cliCommand struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string, cfg config, s storage, out io.Writer) error
+}
+
+var cliCommands = []cliCommand{
+	{
+		name:  "list-databases",
+		usage: "list-databases [-target] [-json]",
+		run:   listDatabasesCommand,
+	},
+	{
+		name:  "list-collections",
+		usage: "list-collections -db <database> [-target] [-json]",
+		run:   listCollectionsCommand,
+	},
+	{
+		name:  "copy",
+		usage: "copy -from <db.collection> -to <db.collection> [-mode replace|append|upsert|insert-missing] [-key <field>] [-filter <json>] [-sort <json>] [-projection <json>] [-limit <n>] [-indexes none|before|after] [-create] [-staging] [-transaction] [-sync | -verify] [-watermark <field>] [-backup none|collection|archive] [-resume] [-dry-run] [-confirm <database>] [-json]",
+		run:   copyCommand,
+	},
+	{
+		name:  "plan",
+		usage: "plan run [-backup none|collection|archive] [-resume] [-verify] [-dry-run] [-confirm <database>] [-json] <plan.json>",
+		run:   planCommand,
+	},
+	{
+		name:  "rollback",
+		usage: "rollback [-confirm <database>] [-json]",
+		run:   rollbackCommand,
+	},
+}
+
+// Run the subcommand named by the first argument. Returned errors carry the exit code for the process.
+// An interrupt cancels the command, leaving checkpoints of unfinished copies to resume from.
+func runCLI(args []string, cfg config, out io.Writer) error {
+	if len(args) == 0 {
+		return exit.Wrap(errors.New(cliUsage()), exit.UsageError)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for _, c := range cliCommands {
+		if c.name == args[0] {
+			s, err := newStorageFromConfig(cfg)
+			if err != nil {
+				return err
+			}
+			return c.run(ctx, args[1:], cfg, s, out)
+		}
+	}
+
+	return exit.Wrap(fmt.Errorf("unknown command %q\n%s", args[0], cliUsage()), exit.UnknownSubcommand)
+}
+
+// Usage of every subcommand
+func cliUsage() string {
+	usage := "usage: mongo-move [command]\n\ncommands:\n"
+	for _, c := range cliCommands {
+		usage += "  " + c.usage + "\n"
+	}
+
+	return usage
+}
+
+// Parse subcommand flags, returning a usage error if they are invalid
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return exit.Wrap(fmt.Errorf("%s: %w", fs.Name(), err), exit.UsageError)
+	}
+	if fs.NArg() > 0 {
+		return exit.Wrap(fmt.Errorf("%s: unexpected arguments %v", fs.Name(), fs.Args()), exit.UsageError)
+	}
+
+	return nil
+}
+
+// Check the source and target servers are known, returning a usage error if they are not
+func requireServers(cfg config) error {
+	if cfg.Source == "" || cfg.Target == "" {
+		return exit.Wrap(errors.New("choose the source and target profiles with -source and -target"), exit.UsageError)
+	}
+
+	return nil
+}
+
+// Create the clients a command shares for all its operations
+func connectServers(command string, s storage) (storage, error) {
+	s, err := s.connect()
+	if err != nil {
+		return s, exit.Wrap(fmt.Errorf("%s: %w", command, err), exit.UsageError)
+	}
+
+	return s, nil
+}
+
+// Write v as indented JSON
+func writeJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Split a namespace in the form database.collection
+func parseNamespace(ns string) (string, string, error) {
+	database, collection, found := strings.Cut(ns, ".")
+	if !found || database == "" || collection == "" {
+		return "", "", fmt.Errorf("namespace %q must be in the form database.collection", ns)
+	}
+
+	return database, collection, nil
+}
+
+func listDatabasesCommand(ctx context.Context, args []string, cfg config, s storage, out io.Writer) error {
+	if err := requireServers(cfg); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("list-databases", flag.ContinueOnError)
+	target := fs.Bool("target", false, "list databases on the target server instead of the source")
+	asJSON := fs.Bool("json", false, "print output as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var databases []string
+	var err error
+	if s, err = connectServers("list-databases", s); err != nil {
+		return err
+	}
+	defer s.disconnect(context.Background())
+
+	if *target {
+		databases, err = s.getTargetDatabases(ctx)
+	} else {
+		databases, err = s.getSourceDatabases(ctx)
+	}
+	if err != nil {
+		return exit.Wrap(err, exit.Unavailable)
+	}
+	sort.Strings(databases)
+
+	if *asJSON {
+		return writeJSON(out, databases)
+	}
+	for _, d := range databases {
+		fmt.Fprintln(out, d)
+	}
+
+	return nil
+}
+
+func listCollectionsCommand(ctx context.Context, args []string, cfg config, s storage, out io.Writer) error {
+	if err := requireServers(cfg); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("list-collections", flag.ContinueOnError)
+	database := fs.String("db", "", "database to list collections of")
+	target := fs.Bool("target", false, "list collections on the target server instead of the source")
+	asJSON := fs.Bool("json", false, "print output as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *database == "" {
+		return exit.Wrap(errors.New("list-collections: -db is required"), exit.UsageError)
+	}
+
+	var collections []collection
+	var err error
+	if s, err = connectServers("list-collections", s); err != nil {
+		return err
+	}
+	defer s.disconnect(context.Background())
+
+	if *target {
+		collections, err = s.getTargetCollections(ctx, *database)
+	} else {
+		collections, err = s.getSourceCollections(ctx, *database)
+	}
+	if err != nil {
+		return exit.Wrap(err, exit.Unavailable)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].name < collections[j].name })
+
+	if *asJSON {
+		type jsonCollection struct {
+			Name    string `json:"name"`
+			Records int64  `json:"records"`
+		}
+		result := []jsonCollection{}
+		for _, c := range collections {
+			result = append(result, jsonCollection{Name: c.name, Records: c.count})
+		}
+		return writeJSON(out, result)
+	}
+	for _, c := range collections {
+		fmt.Fprintf(out, "%s\t%d\n", c.name, c.count)
+	}
+
+	return nil
+}
+
+const dryRunStatus = "Dry run" // Status of a result that previewed its copy without writing
+
+// Result of a headless copy
+type copyResult struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Mode   string `json:"mode"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Notice string `json:"notice,omitempty"` // How the copy differed from what was asked for
+
+	Indexes *indexReport  `json:"indexes,omitempty"` // Set when indexes were copied
+	Verify  *verifyReport `json:"verify,omitempty"`  // Set when the copy was verified
+	DryRun  *dryRunReport `json:"dryRun,omitempty"`  // Set instead of copying on a dry run
+}
+
+func newCopyResult(spec copySpec, report copyReport, err error) copyResult {
+	r := copyResult{
+		Source:  spec.sourceDatabase + "." + spec.sourceCollection,
+		Target:  spec.targetDatabase + "." + spec.targetCollection,
+		Mode:    spec.mode.String(),
+		Status:  taskSucceeded.String(),
+		Indexes: report.indexes,
+		Notice:  report.notice,
+	}
+	if err != nil {
+		r.Status = taskFailed.String()
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+// Result of a dry run, which reports an error when the copy would fail
+func newDryRunResult(spec copySpec, report *dryRunReport, err error) copyResult {
+	r := copyResult{
+		Source: spec.sourceDatabase + "." + spec.sourceCollection,
+		Target: spec.targetDatabase + "." + spec.targetCollection,
+		Mode:   spec.mode.String(),
+		Status: dryRunStatus,
+		DryRun: report,
+	}
+	if err == nil && report != nil && report.Error != "" {
+		err = errors.New(report.Error)
+	}
+	if err != nil {
+		r.Status = taskFailed.String()
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+// Print result as plain text
+func (r copyResult) print(out io.Writer) {
+	if r.DryRun != nil && r.Error == "" {
+		fmt.Fprintf(out, "dry run of %s to %s (%s): %s\n", r.Source, r.Target, r.Mode, r.DryRun)
+		for _, index := range r.DryRun.Indexes {
+			fmt.Fprintf(out, "  would create index %s\n", index)
+		}
+		for _, c := range r.DryRun.Conflicting {
+			fmt.Fprintf(out, "  conflicting index %s\n", c)
+		}
+		return
+	}
+
+	if r.Error != "" {
+		fmt.Fprintf(out, "failed to copy %s to %s (%s): %s\n", r.Source, r.Target, r.Mode, r.Error)
+	} else {
+		fmt.Fprintf(out, "copied %s to %s (%s)\n", r.Source, r.Target, r.Mode)
+	}
+	if r.Notice != "" {
+		fmt.Fprintf(out, "  note: %s\n", r.Notice)
+	}
+	if r.Indexes != nil {
+		fmt.Fprintf(out, "  indexes: %s\n", r.Indexes)
+		for _, c := range r.Indexes.Conflicting {
+			fmt.Fprintf(out, "  conflicting index %s\n", c)
+		}
+	}
+	if r.Verify != nil {
+		fmt.Fprintf(out, "  verify: %s\n", r.Verify)
+	}
+}
+
+// Check if the copy succeeded and passed verification, if it was verified
+func (r copyResult) ok() bool {
+	return r.Error == "" && (r.Verify == nil || r.Verify.ok())
+}
+
+// Save the verify reports of the results that were verified to the report file
+func saveVerifyResults(s storage, results []copyResult) error {
+	var reports []verifyReport
+	for _, r := range results {
+		if r.Verify != nil {
+			reports = append(reports, *r.Verify)
+		}
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+
+	if err := s.saveVerifyReports(reports); err != nil {
+		return fmt.Errorf("failed to save verify reports: %w", err)
+	}
+
+	return nil
+}
+
+func copyCommand(ctx context.Context, args []string, cfg config, s storage, out io.Writer) error {
+	if err := requireServers(cfg); err != nil {
+		return err
+	}
+
+	var spec copySpec
+	fs := flag.NewFlagSet("copy", flag.ContinueOnError)
+	from := fs.String("from", "", "source namespace as database.collection")
+	to := fs.String("to", "", "target namespace as database.collection")
+	mode := fs.String("mode", modeReplace.String(), "write mode: replace, append, upsert or insert-missing")
+	key := fs.String("key", defaultWriteKey, "field matching documents in upsert and insert-missing modes")
+	fs.StringVar(&spec.query.filter, "filter", "", "extended JSON filter choosing the documents to copy")
+	fs.StringVar(&spec.query.sort, "sort", "", "extended JSON sort order of the documents to copy")
+	fs.StringVar(&spec.query.projection, "projection", "", "extended JSON projection of the fields to copy")
+	fs.Int64Var(&spec.query.limit, "limit", 0, "maximum number of documents to copy")
+	fs.BoolVar(&spec.resume, "resume", false, "continue a copy that stopped part way from its checkpoint")
+	fs.BoolVar(&spec.create, "create", false, "create the target collection with the source collection's options")
+	fs.BoolVar(&spec.staging, "staging", false, "load a staging collection that replaces the target once complete, replace mode only")
+	fs.BoolVar(&spec.transaction, "transaction", false, "delete and write the target documents in one transaction on replica sets")
+	fs.BoolVar(&spec.sync, "sync", false, "keep applying source changes to the target after copying until interrupted")
+	fs.StringVar(&spec.watermark, "watermark", "", "increasing field, only copy documents above its highest value in the last copy")
+	indexes := fs.String("indexes", indexesNone.String(), "copy source indexes: none, before or after the documents")
+	backup := fs.String("backup", cfg.Backup, "save target documents before deleting them: none, collection or archive")
+	verify := fs.Bool("verify", false, "compare the target with the source after copying and save a verify report, not with -sync")
+	dryRun := fs.Bool("dry-run", false, "report what the copy would delete, insert and create without writing anything")
+	confirm := fs.String("confirm", "", "target database name, required to copy to a protected target")
+	asJSON := fs.Bool("json", false, "print output as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var err error
+	if spec.sourceDatabase, spec.sourceCollection, err = parseNamespace(*from); err != nil {
+		return exit.Wrap(fmt.Errorf("copy: -from: %w", err), exit.UsageError)
+	}
+	if spec.targetDatabase, spec.targetCollection, err = parseNamespace(*to); err != nil {
+		return exit.Wrap(fmt.Errorf("copy: -to: %w", err), exit.UsageError)
+	}
+	if spec.mode, err = parseWriteMode(*mode); err != nil {
+		return exit.Wrap(fmt.Errorf("copy: -mode: %w", err), exit.UsageError)
+	}
+	spec.key = *key
+	if spec.staging && spec.mode != modeReplace {
+		return exit.Wrap(errors.New("copy: -staging only works with -mode replace"), exit.UsageError)
+	}
+	if spec.transaction {
+		if err := spec.validTransaction(); err != nil {
+			return exit.Wrap(fmt.Errorf("copy: -transaction: %w", err), exit.UsageError)
+		}
+	}
+	if spec.sync {
+		if err := spec.validSync(); err != nil {
+			return exit.Wrap(fmt.Errorf("copy: -sync: %w", err), exit.UsageError)
+		}
+		// Syncs only stop when interrupted, so there is no finished copy to verify
+		if *verify {
+			return exit.Wrap(errors.New("copy: -verify cannot be used with -sync"), exit.UsageError)
+		}
+		if !*asJSON {
+			spec.progress = syncPrinter(out, *to)
+		}
+	}
+	if spec.watermark != "" {
+		if err := spec.validIncremental(); err != nil {
+			return exit.Wrap(fmt.Errorf("copy: -watermark: %w", err), exit.UsageError)
+		}
+	}
+	if spec.indexes, err = parseIndexCopy(*indexes); err != nil {
+		return exit.Wrap(fmt.Errorf("copy: -indexes: %w", err), exit.UsageError)
+	}
+	if spec.backup, err = parseBackupMode(*backup); err != nil {
+		return exit.Wrap(fmt.Errorf("copy: -backup: %w", err), exit.UsageError)
+	}
+	if _, err := spec.query.parse(); err != nil {
+		return exit.Wrap(fmt.Errorf("copy: %w", err), exit.UsageError)
+	}
+	if !*dryRun {
+		if err := confirmTarget("copy", cfg, s, spec.targetDatabase, *confirm); err != nil {
+			return err
+		}
+	}
+
+	if s, err = connectServers("copy", s); err != nil {
+		return err
+	}
+	defer s.disconnect(context.Background())
+
+	var result copyResult
+	if *dryRun {
+		result = newDryRunResult(spec, s.dryRunResult(ctx, spec), nil)
+	} else if spec.sync {
+		report, syncErr := s.sync(ctx, spec, func(initial func() error) error { return initial() })
+		result = newCopyResult(spec, report, syncErr)
+	} else {
+		report, copyErr := s.copy(ctx, spec)
+		result = newCopyResult(spec, report, copyErr)
+		if copyErr == nil && *verify {
+			result.Verify = s.verifyResult(ctx, spec)
+		}
+	}
+
+	if *asJSON {
+		if err := writeJSON(out, result); err != nil {
+			return err
+		}
+	} else {
+		result.print(out)
+	}
+
+	if err := saveVerifyResults(s, []copyResult{result}); err != nil {
+		return err
+	}
+	if !result.ok() {
+		return exit.ErrNotOK
+	}
+
+	return nil
+}
+
+func planCommand(ctx context.Context, args []string, cfg config, s storage, out io.Writer) error {
+	if len(args) == 0 || args[0] != "run" {
+		return exit.Wrap(errors.New("usage: mongo-move plan run [-backup none|collection|archive] [-resume] [-verify] [-dry-run] [-confirm <database>] [-json] <plan.json>"), exit.UsageError)
+	}
+
+	fs := flag.NewFlagSet("plan run", flag.ContinueOnError)
+	resume := fs.Bool("resume", false, "continue tasks that stopped part way from their checkpoints")
+	backup := fs.String("backup", cfg.Backup, "save target documents before deleting them: none, collection or archive")
+	verify := fs.Bool("verify", false, "compare each target with its source after copying and save verify reports")
+	dryRun := fs.Bool("dry-run", false, "report what each task would delete, insert and create without writing anything")
+	confirm := fs.String("confirm", "", "target database name, required to run a plan against a protected target")
+	asJSON := fs.Bool("json", false, "print output as JSON")
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args[1:]); err != nil {
+		return exit.Wrap(fmt.Errorf("plan run: %w", err), exit.UsageError)
+	}
+	if fs.NArg() != 1 {
+		return exit.Wrap(errors.New("plan run: expected a single plan file"), exit.UsageError)
+	}
+	backupMode, err := parseBackupMode(*backup)
+	if err != nil {
+		return exit.Wrap(fmt.Errorf("plan run: -backup: %w", err), exit.UsageError)
+	}
+
+	p, err := loadPlan(fs.Arg(0))
+	if err != nil {
+		return exit.Wrap(fmt.Errorf("plan run: %w", err), exit.UsageError)
+	}
+
+	s, err = p.storage(s, cfg)
+	if err != nil {
+		return exit.Wrap(fmt.Errorf("plan run: %w", err), exit.UsageError)
+	}
+	if !*dryRun {
+		if err := confirmTarget("plan run", cfg, s, p.Target.Database, *confirm); err != nil {
+			return err
+		}
+	}
+	if s, err = connectServers("plan run", s); err != nil {
+		return err
+	}
+	defer s.disconnect(context.Background())
+
+	opts := planRunOptions{resume: *resume, verify: *verify && !*dryRun, dryRun: *dryRun, backup: backupMode}
+	results := p.run(ctx, s, newScheduler(cfg.MaxConcurrentCopies), opts)
+
+	if *asJSON {
+		if err := writeJSON(out, results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			r.print(out)
+		}
+	}
+
+	if err := saveVerifyResults(s, results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.ok() {
+			return exit.ErrNotOK
+		}
+	}
+
+	return nil
+}
+
+// Print how far a headless sync has got, at most every 10 seconds once it applies changes
+func syncPrinter(out io.Writer, target string) func(copyProgress) {
+	var printed time.Time
+	return func(p copyProgress) {
+		if !p.syncing || time.Since(printed) < 10*time.Second {
+			return
+		}
+		if printed.IsZero() {
+			fmt.Fprintf(out, "syncing changes to %s, stop with ctrl+c\n", target)
+		}
+		printed = time.Now()
+		fmt.Fprintf(out, "  sync: %d changes, lag %s\n", p.changes, p.lag.Round(time.Second))
+	}
+}
+
+func rollbackCommand(ctx context.Context, args []string, cfg config, s storage, out io.Writer) error {
+	if err := requireServers(cfg); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	confirm := fs.String("confirm", "", "target database name, required to roll back a protected target")
+	asJSON := fs.Bool("json", false, "print output as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// Rolling back replaces target documents, so protected targets are confirmed as for a copy
+	confirmed := map[string]bool{}
+	for _, r := range s.state.lastRunBackups() {
+		if !confirmed[r.Database] {
+			if err := confirmTarget("rollback", cfg, s, r.Database, *confirm); err != nil {
+				return err
+			}
+			confirmed[r.Database] = true
+		}
+	}
+
+	s, err := connectServers("rollback", s)
+	if err != nil {
+		return err
+	}
+	defer s.disconnect(context.Background())
+
+	records, err := s.rollback(ctx)
+	if err != nil {
+		return fmt.Errorf("rollback: %w", err)
+	}
+
+	if *asJSON {
+		return writeJSON(out, records)
+	}
+	for _, r := range records {
+		fmt.Fprintf(out, "%s.%s: restored %d documents from %s backup %s\n", r.Database, r.Collection, r.Documents, r.Mode, r.Backup)
+	}
+
+	return nil
+}
+
+// Error without the exit code it is wrapped with, for printing. Returns nil for exit codes
+// without a cause, where the command has already printed what went wrong.
+func exitCause(err error) error {
+	var e exit.Error
+	if errors.As(err, &e) {
+		return e.Cause
+	}
+
+	return err
+}
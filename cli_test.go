@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/square/exit"
+)
+
+func TestParseNamespace(t *testing.T) {
+	database, collection, err := parseNamespace("shop.orders.archive")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if database != "shop" || collection != "orders.archive" {
+		t.Errorf("unexpected namespace %s %s", database, collection)
+	}
+
+	for _, ns := range []string{"shop", ".orders", "shop."} {
+		if _, _, err := parseNamespace(ns); err == nil {
+			t.Errorf("expected error for namespace %q", ns)
+		}
+	}
+}
+
+func TestRunCLI_NoCommand(t *testing.T) {
+	err := runCLI([]string{}, config{Source: "source", Target: "target"}, &bytes.Buffer{})
+	if exit.FromError(err) != exit.UsageError {
+		t.Errorf("expected usage error, got %v", err)
+	}
+}
+
+func TestRunCLI_UnknownCommand(t *testing.T) {
+	err := runCLI([]string{"move"}, config{Source: "source", Target: "target"}, &bytes.Buffer{})
+	if exit.FromError(err) != exit.UnknownSubcommand {
+		t.Errorf("expected unknown subcommand error, got %v", err)
+	}
+}
+
+func TestRunCLI_InvalidFlags(t *testing.T) {
+	tests := [][]string{
+		{"list-databases", "-verbose"},
+		{"list-collections"},
+		{"copy", "-from", "shop", "-to", "shop.orders"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-mode", "merge"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-filter", "{tenant: acme}"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-backup", "disk"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-mode", "append", "-staging"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-transaction", "-resume"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-sync", "-limit", "10"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-sync", "-mode", "upsert", "-key", "email"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-watermark", "updatedAt"},
+		{"copy", "-from", "shop.orders", "-to", "backup.orders", "-sync", "-verify"},
+		{"plan"},
+		{"plan", "run"},
+		{"plan", "run", "missing-plan.json"},
+		{"plan", "run", "-backup", "disk", "plan.json"},
+		{"rollback", "last"},
+	}
+
+	for _, args := range tests {
+		err := runCLI(args, config{Source: "source", Target: "target"}, &bytes.Buffer{})
+		if exit.FromError(err) != exit.UsageError {
+			t.Errorf("%v: expected usage error, got %v", args, err)
+		}
+	}
+}
+
+func TestExitCause(t *testing.T) {
+	cause := errors.New("connection refused")
+	if got := exitCause(exit.Wrap(cause, exit.Unavailable)); got != cause {
+		t.Errorf("expected %v, got %v", cause, got)
+	}
+	if got := exitCause(cause); got != cause {
+		t.Errorf("expected %v, got %v", cause, got)
+	}
+	if got := exitCause(exit.ErrNotOK); got != nil {
+		t.Errorf("expected no cause, got %v", got)
+	}
+}
+
+func TestRunCLI_NoServers(t *testing.T) {
+	cfg := config{Profiles: []profile{{Name: "dev", Server: "mongodb://localhost:27017"}}}
+	err := runCLI([]string{"list-databases"}, cfg, &bytes.Buffer{})
+	if exit.FromError(err) != exit.UsageError {
+		t.Errorf("expected usage error without chosen profiles, got %v", err)
+	}
+}
+
+func TestCopyResultOk(t *testing.T) {
+	if !(copyResult{}).ok() {
+		t.Error("expected copy without error or verify report to pass")
+	}
+	if (copyResult{Error: "failed"}).ok() {
+		t.Error("expected failed copy not to pass")
+	}
+	if (copyResult{Verify: &verifyReport{SourceCount: 2, TargetCount: 1}}).ok() {
+		t.Error("expected copy failing verification not to pass")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Create the target collection with the options of the source collection, such as capped size,
+// validator, collation and time-series settings. A target that already exists is left as it is.
+// A source view is created as a view with the same pipeline, reported by the returned flag.
+func (s storage) createCollection(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection) (bool, error) {
+	specs, err := sc.Database().ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: sc.Name()}})
+	if err != nil {
+		return false, fmt.Errorf("failed to read source collection options: %w", err)
+	} else if len(specs) == 0 {
+		return false, fmt.Errorf("source collection %s does not exist", sc.Name())
+	}
+	view := specs[0].Type == "view"
+
+	cmd, err := createCommand(tc.Name(), specs[0].Options)
+	if err != nil {
+		return view, err
+	}
+
+	err = tc.Database().RunCommand(ctx, cmd).Err()
+	if isNamespaceExists(err) {
+		return view, nil
+	} else if err != nil {
+		return view, fmt.Errorf("failed to create target collection: %w", err)
+	}
+
+	return view, nil
+}
+
+// Build the create command for a collection with the options listed for another collection
+func createCommand(name string, options bson.Raw) (bson.D, error) {
+	cmd := bson.D{{Key: "create", Value: name}}
+	if len(options) == 0 {
+		return cmd, nil
+	}
+
+	var opts bson.D
+	if err := bson.Unmarshal(options, &opts); err != nil {
+		return nil, fmt.Errorf("invalid source collection options: %w", err)
+	}
+
+	return append(cmd, opts...), nil
+}
+
+// Check if a collection name can be used for a new target collection
+func validCollectionName(name string) error {
+	if name == "" {
+		return errors.New("collection name is empty")
+	}
+	if strings.ContainsAny(name, "$\x00") {
+		return fmt.Errorf("collection name %q must not contain '$' or null characters", name)
+	}
+	if strings.HasPrefix(name, "system.") {
+		return fmt.Errorf("collection name %q must not start with \"system.\"", name)
+	}
+
+	return nil
+}
+
+// Check if a command failed because its collection already exists
+func isNamespaceExists(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 48
+}
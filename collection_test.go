@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestCreateCommand(t *testing.T) {
+	options, err := bson.Marshal(bson.D{{Key: "capped", Value: true}, {Key: "size", Value: 4096}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := createCommand("events", options)
+	if err != nil {
+		t.Fatalf("failed to build create command: %v", err)
+	}
+	if len(cmd) != 3 || cmd[0].Key != "create" || cmd[0].Value != "events" || cmd[1].Key != "capped" {
+		t.Errorf("unexpected create command %v", cmd)
+	}
+
+	if cmd, _ := createCommand("events", nil); len(cmd) != 1 {
+		t.Errorf("expected create command without options, got %v", cmd)
+	}
+}
+
+func TestValidCollectionName(t *testing.T) {
+	if err := validCollectionName("orders_2024"); err != nil {
+		t.Errorf("expected valid name, got %v", err)
+	}
+	for _, name := range []string{"", "price$", "system.views"} {
+		if err := validCollectionName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestIsNamespaceExists(t *testing.T) {
+	if !isNamespaceExists(mongo.CommandError{Code: 48}) {
+		t.Error("expected NamespaceExists error to be recognised")
+	}
+	if isNamespaceExists(mongo.CommandError{Code: 26}) || isNamespaceExists(nil) {
+		t.Error("expected other errors not to be NamespaceExists")
+	}
+}
+
+func TestCollectionString(t *testing.T) {
+	if s := (collection{name: "orders"}).String(); s != "orders" {
+		t.Errorf("expected orders, got %s", s)
+	}
+	if s := (collection{name: "orders", create: true}).String(); s != "orders (new)" {
+		t.Errorf("expected new collection to be marked, got %s", s)
+	}
+	if s := (collection{name: "active_orders", view: true}).String(); s != "active_orders (view)" {
+		t.Errorf("expected view to be marked, got %s", s)
+	}
+}
+
+func TestAddNewTargetCopyTask(t *testing.T) {
+	m := model{}
+	m.databaseChoices.targetCollections = []collection{{name: "orders"}}
+	m.collectionChoices.currentCopyTask.source = collection{name: "orders", count: 10}
+
+	if _, err := m.addNewTargetCopyTask("orders"); err == nil {
+		t.Error("expected error for a target collection that already exists")
+	}
+	if _, err := m.addNewTargetCopyTask("orders_copy"); err != nil {
+		t.Fatalf("failed to add copy task: %v", err)
+	}
+	if len(m.collectionChoices.copyTasks) != 1 {
+		t.Fatalf("expected 1 copy task, got %d", len(m.collectionChoices.copyTasks))
+	}
+	task := m.collectionChoices.copyTasks[0]
+	if !task.target.create || !m.copySpec(task).create {
+		t.Error("expected new target collection to be created by the copy")
+	}
+	if _, err := m.addNewTargetCopyTask("orders_copy"); err == nil {
+		t.Error("expected error for a target already used by a copy task")
+	}
+}
@@ -2,11 +2,42 @@ package main
 
 import (
 	"fmt"
+	"net/url"
+	"path"
+	"strings"
 )
 
+// Named server connection, chosen as the source or target when the program starts
+type profile struct {
+	Name      string `json:"name"`
+	Server    string `json:"server"`
+	Protected bool   `json:"protected"` // Copies to the server have to be confirmed by typing the target database name
+}
+
 type config struct {
-	Source string `json:"sourceServer"`
-	Target string `json:"targetServer"`
+	Source     string    `json:"sourceServer"` // Used when no profiles are set
+	Target     string    `json:"targetServer"` // Used when no profiles are set
+	Profiles   []profile `json:"profiles"`
+	BatchSize  int       `json:"batchSize"`  // Maximum number of documents per bulk write, defaults to 1000
+	BatchBytes int       `json:"batchBytes"` // Maximum size in bytes of a bulk write, defaults to 16MB
+
+	MaxConcurrentCopies   int   `json:"maxConcurrentCopies"`   // Collections copied at once, defaults to 4
+	PartitionWorkers      int   `json:"partitionWorkers"`      // Workers copying _id ranges of one collection, defaults to 1
+	PartitionMinDocuments int64 `json:"partitionMinDocuments"` // Smallest collection split between workers, defaults to 100000
+
+	StateFile string `json:"stateFile"` // File checkpoints of stopped copies are saved to, defaults to mongo-move.state.json
+
+	MaxPoolSize        int `json:"maxPoolSize"`        // Most connections per server, defaults to the driver's 100
+	MinPoolSize        int `json:"minPoolSize"`        // Connections per server kept open while idle, defaults to 0
+	MaxConnIdleSeconds int `json:"maxConnIdleSeconds"` // Idle connections are closed after this long, defaults to never
+
+	VerifySamples    int    `json:"verifySamples"`    // Documents compared one by one when verifying a copy, defaults to 100
+	VerifyReportFile string `json:"verifyReportFile"` // File verify reports are saved to, defaults to mongo-move.verify.json
+
+	ProtectedDatabases []string `json:"protectedDatabases"` // Glob patterns of target databases copies to have to be confirmed
+
+	Backup    string `json:"backup"`    // Where target documents are saved before a copy deletes them: none, collection or archive, defaults to none
+	BackupDir string `json:"backupDir"` // Directory archive backups are written to, defaults to mongo-move-backups
 }
 
 func load() (config, error) {
@@ -20,11 +51,136 @@ func load() (config, error) {
 }
 
 func (c config) validate() error {
-	if c.Source == "" {
-		return fmt.Errorf("config value \"Source\" is missing")
-	} else if c.Target == "" {
-		return fmt.Errorf("config value \"Target\" is missing")
+	if len(c.Profiles) == 0 {
+		if c.Source == "" {
+			return fmt.Errorf("config value \"Source\" is missing")
+		} else if c.Target == "" {
+			return fmt.Errorf("config value \"Target\" is missing")
+		}
+	}
+
+	names := map[string]bool{}
+	for i, p := range c.Profiles {
+		if p.Name == "" {
+			return fmt.Errorf("config profile %d is missing \"name\"", i+1)
+		} else if names[p.Name] {
+			return fmt.Errorf("config profile %q is defined more than once", p.Name)
+		} else if p.Server == "" {
+			return fmt.Errorf("config profile %q is missing \"server\"", p.Name)
+		} else if _, err := url.Parse(p.Server); err != nil {
+			return fmt.Errorf("config profile %q has an invalid server: %w", p.Name, err)
+		}
+		names[p.Name] = true
+	}
+
+	for _, pattern := range c.ProtectedDatabases {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("config value \"protectedDatabases\" has an invalid pattern %q", pattern)
+		}
+	}
+
+	if _, err := parseBackupMode(c.Backup); err != nil {
+		return fmt.Errorf("config value \"backup\" is invalid: %w", err)
+	}
+
+	if c.BatchSize < 0 {
+		return fmt.Errorf("config value \"batchSize\" must not be negative")
+	} else if c.BatchBytes < 0 {
+		return fmt.Errorf("config value \"batchBytes\" must not be negative")
+	} else if c.MaxConcurrentCopies < 0 {
+		return fmt.Errorf("config value \"maxConcurrentCopies\" must not be negative")
+	} else if c.PartitionWorkers < 0 {
+		return fmt.Errorf("config value \"partitionWorkers\" must not be negative")
+	} else if c.PartitionMinDocuments < 0 {
+		return fmt.Errorf("config value \"partitionMinDocuments\" must not be negative")
+	} else if c.MaxPoolSize < 0 {
+		return fmt.Errorf("config value \"maxPoolSize\" must not be negative")
+	} else if c.MinPoolSize < 0 {
+		return fmt.Errorf("config value \"minPoolSize\" must not be negative")
+	} else if c.MaxPoolSize > 0 && c.MinPoolSize > c.MaxPoolSize {
+		return fmt.Errorf("config value \"minPoolSize\" must not be greater than \"maxPoolSize\"")
+	} else if c.MaxConnIdleSeconds < 0 {
+		return fmt.Errorf("config value \"maxConnIdleSeconds\" must not be negative")
+	} else if c.VerifySamples < 0 {
+		return fmt.Errorf("config value \"verifySamples\" must not be negative")
 	} else {
 		return nil
 	}
 }
+
+// Profiles to choose the source and target from. Without profiles in config,
+// the source and target servers are used as profiles named source and target.
+func (c config) profiles() []profile {
+	if len(c.Profiles) > 0 {
+		return c.Profiles
+	}
+
+	return []profile{
+		{Name: "source", Server: c.Source},
+		{Name: "target", Server: c.Target},
+	}
+}
+
+// Find profile by name
+func (c config) profile(name string) (profile, error) {
+	for _, p := range c.profiles() {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	return profile{}, fmt.Errorf("config profile %q does not exist", name)
+}
+
+// Use the servers of the named profiles as the source and target, leaving a server unchanged if its name is empty
+func (c config) useProfiles(source string, target string) (config, error) {
+	if source != "" {
+		p, err := c.profile(source)
+		if err != nil {
+			return c, err
+		}
+		c.Source = p.Server
+	}
+	if target != "" {
+		p, err := c.profile(target)
+		if err != nil {
+			return c, err
+		}
+		c.Target = p.Server
+	}
+
+	return c, nil
+}
+
+// Host of the profile server, leaving out credentials
+func (p profile) host() string {
+	return serverHost(p.Server)
+}
+
+// Host of a server connection string, leaving out credentials
+func serverHost(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return uri
+	}
+
+	return u.Host
+}
+
+// Server connection string without the user name and password, for files that may be shared.
+// Connection strings can list several hosts, which url.Parse does not accept.
+func withoutCredentials(uri string) string {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return uri
+	}
+
+	end := strings.IndexAny(rest, "/?")
+	if end < 0 {
+		end = len(rest)
+	}
+	if at := strings.LastIndex(rest[:end], "@"); at >= 0 {
+		rest = rest[at+1:]
+	}
+	return scheme + "://" + rest
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Glob patterns choosing the collections of a whole-database copy. A collection is copied when it
+// matches any include pattern, or there are none, and matches no exclude pattern.
+type collectionPatterns struct {
+	include []string
+	exclude []string
+}
+
+// Parse comma or space separated glob patterns such as "orders*, !*_tmp", where a leading ! excludes
+// the collections matching the rest of the pattern
+func parseCollectionPatterns(s string) (collectionPatterns, error) {
+	var p collectionPatterns
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	for _, f := range fields {
+		pattern, exclude := strings.CutPrefix(f, "!")
+		if pattern == "" {
+			return p, fmt.Errorf("empty pattern in %q", f)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return p, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+
+		if exclude {
+			p.exclude = append(p.exclude, pattern)
+		} else {
+			p.include = append(p.include, pattern)
+		}
+	}
+
+	return p, nil
+}
+
+// Check if a collection is copied. System collections are never copied.
+func (p collectionPatterns) match(name string) bool {
+	if strings.HasPrefix(name, "system.") {
+		return false
+	}
+	for _, pattern := range p.exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(p.include) == 0 {
+		return true
+	}
+	for _, pattern := range p.include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p collectionPatterns) String() string {
+	patterns := append([]string{}, p.include...)
+	for _, pattern := range p.exclude {
+		patterns = append(patterns, "!"+pattern)
+	}
+
+	return strings.Join(patterns, ", ")
+}
+
+// Turn every source collection matching the whole-database patterns into a copy task to the target
+// collection with the same name, taking the collections out of the source and target tables. Targets
+// that do not exist and views are created with the source options. Views are copied after collections.
+// Views whose name is taken in the target cannot be created there, so they are left in the tables to
+// pair by hand and their names returned.
+func (m *model) applyDatabaseCopy() []string {
+	patterns := *m.databaseChoices.wholeDatabase
+	m.databaseChoices.wholeDatabase = nil
+
+	var collections, views []collectionCopyTask
+	var sources []collection
+	conflicts := []string{}
+	for _, source := range m.databaseChoices.sourceCollections {
+		if !patterns.match(source.name) {
+			sources = append(sources, source)
+			continue
+		}
+
+		target := collection{name: source.name, create: true}
+		if ti := collectionIndex(m.databaseChoices.targetCollections, source.name); ti >= 0 {
+			if source.view {
+				sources = append(sources, source)
+				conflicts = append(conflicts, source.name)
+				continue
+			}
+			target = m.databaseChoices.targetCollections[ti]
+			target.create = false
+			m.databaseChoices.targetCollections = removeItem(m.databaseChoices.targetCollections, ti)
+		}
+
+		if source.view {
+			views = append(views, newCollectionCopyTask(source, target))
+		} else {
+			collections = append(collections, newCollectionCopyTask(source, target))
+		}
+	}
+
+	m.databaseChoices.sourceCollections = sources
+	m.collectionChoices.copyTasks = append(m.collectionChoices.copyTasks, append(collections, views...)...)
+	return conflicts
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseCollectionPatterns(t *testing.T) {
+	p, err := parseCollectionPatterns("orders*, !*_tmp  users")
+	if err != nil {
+		t.Fatalf("failed to parse patterns: %v", err)
+	}
+	if len(p.include) != 2 || p.include[0] != "orders*" || p.include[1] != "users" {
+		t.Errorf("unexpected include patterns %v", p.include)
+	}
+	if len(p.exclude) != 1 || p.exclude[0] != "*_tmp" {
+		t.Errorf("unexpected exclude patterns %v", p.exclude)
+	}
+	if s := p.String(); s != "orders*, users, !*_tmp" {
+		t.Errorf("unexpected patterns string %s", s)
+	}
+
+	if p, err := parseCollectionPatterns(""); err != nil || len(p.include)+len(p.exclude) != 0 {
+		t.Errorf("expected no patterns, got %v, %v", p, err)
+	}
+	for _, s := range []string{"!", "orders[", "!["} {
+		if _, err := parseCollectionPatterns(s); err == nil {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestCollectionPatternsMatch(t *testing.T) {
+	p, _ := parseCollectionPatterns("orders*, !*_tmp")
+	cases := map[string]bool{
+		"orders":          true,
+		"orders_2024":     true,
+		"orders_tmp":      false,
+		"users":           false,
+		"system.profile":  false,
+		"system.js":       false,
+		"orders_2024_tmp": false,
+	}
+	for name, want := range cases {
+		if got := p.match(name); got != want {
+			t.Errorf("match(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	all := collectionPatterns{}
+	if !all.match("users") || all.match("system.views") {
+		t.Error("expected empty patterns to match every collection except system collections")
+	}
+}
+
+func TestApplyDatabaseCopy(t *testing.T) {
+	m := model{}
+	m.databaseChoices.sourceCollections = []collection{
+		{name: "active_orders", view: true},
+		{name: "orders", count: 5},
+		{name: "orders_tmp", count: 1},
+		{name: "users", count: 2},
+	}
+	m.databaseChoices.targetCollections = []collection{{name: "users", count: 7}, {name: "audit"}}
+	m.databaseChoices.wholeDatabase = &collectionPatterns{exclude: []string{"*_tmp"}}
+
+	if conflicts := m.applyDatabaseCopy(); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %v", conflicts)
+	}
+
+	if m.databaseChoices.wholeDatabase != nil {
+		t.Error("expected patterns to be cleared once applied")
+	}
+	tasks := m.collectionChoices.copyTasks
+	if len(tasks) != 3 {
+		t.Fatalf("expected 3 copy tasks, got %d", len(tasks))
+	}
+	if tasks[0].source.name != "orders" || tasks[0].target.name != "orders" || !tasks[0].target.create {
+		t.Errorf("expected orders to be copied to a new collection, got %v -> %v", tasks[0].source, tasks[0].target)
+	}
+	if tasks[1].target.name != "users" || tasks[1].target.create || tasks[1].target.count != 7 {
+		t.Errorf("expected users to be copied to the existing collection, got %v", tasks[1].target)
+	}
+	if !tasks[2].source.view || tasks[2].target.name != "active_orders" || !tasks[2].target.create {
+		t.Errorf("expected the view to be created last, got %v -> %v", tasks[2].source, tasks[2].target)
+	}
+
+	if len(m.databaseChoices.sourceCollections) != 1 || m.databaseChoices.sourceCollections[0].name != "orders_tmp" {
+		t.Errorf("expected only excluded collections to be left, got %v", m.databaseChoices.sourceCollections)
+	}
+	if len(m.databaseChoices.targetCollections) != 1 || m.databaseChoices.targetCollections[0].name != "audit" {
+		t.Errorf("expected used targets to be taken out, got %v", m.databaseChoices.targetCollections)
+	}
+}
+
+func TestApplyDatabaseCopy_ViewConflict(t *testing.T) {
+	m := model{}
+	m.databaseChoices.sourceCollections = []collection{{name: "active_orders", view: true}, {name: "orders", count: 5}}
+	m.databaseChoices.targetCollections = []collection{{name: "active_orders", count: 3}}
+	m.databaseChoices.wholeDatabase = &collectionPatterns{}
+
+	conflicts := m.applyDatabaseCopy()
+	if len(conflicts) != 1 || conflicts[0] != "active_orders" {
+		t.Errorf("expected the view to conflict, got %v", conflicts)
+	}
+	if tasks := m.collectionChoices.copyTasks; len(tasks) != 1 || tasks[0].source.name != "orders" {
+		t.Errorf("expected only orders to be queued, got %v", tasks)
+	}
+
+	// The view and the target it clashes with are left to pair by hand
+	if len(m.databaseChoices.sourceCollections) != 1 || m.databaseChoices.sourceCollections[0].name != "active_orders" {
+		t.Errorf("expected the view to stay in the source table, got %v", m.databaseChoices.sourceCollections)
+	}
+	if len(m.databaseChoices.targetCollections) != 1 || m.databaseChoices.targetCollections[0].name != "active_orders" {
+		t.Errorf("expected the target to stay in the target table, got %v", m.databaseChoices.targetCollections)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// What a copy would do to the target, worked out without writing anything
+type dryRunReport struct {
+	Source      string   `json:"source"`
+	Target      string   `json:"target"`
+	Create      bool     `json:"create,omitempty"`             // Target collection would be created with the source options
+	Staged      bool     `json:"staged,omitempty"`             // Target would be replaced by a loaded staging collection
+	Since       string   `json:"since,omitempty"`              // Watermark an incremental copy would copy documents above, as extended JSON
+	Deleted     int64    `json:"deleted"`                      // Target documents deleted before writing
+	Inserted    int64    `json:"inserted"`                     // Source documents written, matched on the key in keyed modes
+	Bytes       int64    `json:"bytes"`                        // Estimated size of the documents written
+	Indexes     []string `json:"indexes,omitempty"`            // Source indexes that would be created on the target
+	Conflicting []string `json:"conflictingIndexes,omitempty"` // Source indexes the target would refuse
+	Error       string   `json:"error,omitempty"`              // Why the copy would fail or could not be previewed
+}
+
+// Short summary for the copy task table and command output
+func (r dryRunReport) String() string {
+	if r.Error != "" {
+		return "dry run failed: " + r.Error
+	}
+
+	parts := []string{
+		fmt.Sprintf("delete %d", r.Deleted),
+		fmt.Sprintf("insert %d (%s)", r.Inserted, formatBytes(r.Bytes)),
+	}
+	if len(r.Indexes) > 0 {
+		parts = append(parts, fmt.Sprintf("create %d indexes", len(r.Indexes)))
+	}
+	if len(r.Conflicting) > 0 {
+		parts = append(parts, fmt.Sprintf("%d conflicting indexes", len(r.Conflicting)))
+	}
+	if r.Create {
+		parts = append(parts, "create collection")
+	}
+	if r.Staged {
+		parts = append(parts, "swap in staging collection")
+	}
+	if r.Since != "" {
+		parts = append(parts, "newer than the last copy")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Size in bytes with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Work out what copying the spec would delete, insert and create on the target, only reading from
+// both servers. Checkpoints are read but never changed.
+func (s storage) dryRun(ctx context.Context, spec copySpec) (dryRunReport, error) {
+	report := dryRunReport{
+		Source: spec.sourceDatabase + "." + spec.sourceCollection,
+		Target: spec.targetDatabase + "." + spec.targetCollection,
+		Staged: spec.staging,
+	}
+
+	sClient, releaseSource, err := s.source(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseSource()
+
+	tClient, releaseTarget, err := s.target(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseTarget()
+
+	sc := sClient.Database(spec.sourceDatabase).Collection(spec.sourceCollection)
+	tc := tClient.Database(spec.targetDatabase).Collection(spec.targetCollection)
+
+	query, err := spec.query.parse()
+	if err != nil {
+		return report, err
+	}
+
+	if spec.create {
+		sourceSpec, err := collectionSpecification(ctx, sc)
+		if err != nil {
+			return report, fmt.Errorf("failed to read source collection options: %w", err)
+		} else if sourceSpec == nil {
+			return report, fmt.Errorf("source collection %s does not exist", sc.Name())
+		}
+		targetSpec, err := collectionSpecification(ctx, tc)
+		if err != nil {
+			return report, fmt.Errorf("failed to read target collection: %w", err)
+		}
+		report.Create = targetSpec == nil
+
+		// Views have no documents of their own
+		if sourceSpec.Type == "view" {
+			return report, nil
+		}
+	}
+
+	// Incremental copies are narrowed the same way without saving the pending watermark
+	if spec.watermark != "" {
+		if err := spec.validIncremental(); err != nil {
+			return report, err
+		}
+		saved, last, high, err := s.watermarkBounds(ctx, sc, spec, query)
+		if err != nil {
+			return report, err
+		}
+		query.filter = query.and(watermarkFilter(spec.watermark, last, high))
+		report.Since = saved.Value
+	}
+
+	count, err := s.getRecordCount(ctx, sClient, spec.sourceDatabase, spec.sourceCollection, query.and(bson.D{}), query.countOptions())
+	if err != nil {
+		return report, err
+	} else if count == 0 && !spec.create && spec.watermark == "" {
+		return report, errors.New("no records in source collection to copy")
+	}
+
+	// Resumed copies only write the documents left in their ranges and never empty the target
+	if spec.resume {
+		cp, ok := s.state.checkpoint(s.copyKey(spec))
+		if !ok {
+			return report, errors.New("no checkpoint to resume copy from")
+		}
+		for _, rc := range cp.Ranges {
+			if rc.Done {
+				continue
+			}
+			r, last, err := rc.idRange()
+			if err != nil {
+				return report, fmt.Errorf("invalid checkpoint: %w", err)
+			}
+			remaining, err := s.getRecordCount(ctx, sClient, spec.sourceDatabase, spec.sourceCollection, query.and(r.filter(last)), query.countOptions())
+			if err != nil {
+				return report, err
+			}
+			report.Inserted += remaining
+		}
+	} else {
+		report.Inserted = count
+		if spec.mode.destructive() {
+			if report.Deleted, err = s.getRecordCount(ctx, tClient, spec.targetDatabase, spec.targetCollection, bson.D{}); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	// Estimated from the average source document size, as projections and filters change the real size
+	avg, err := averageDocumentSize(ctx, sc)
+	if err != nil {
+		return report, fmt.Errorf("failed to read source collection size: %w", err)
+	}
+	report.Bytes = avg * report.Inserted
+
+	if spec.indexes != indexesNone {
+		source, err := listIndexes(ctx, sc)
+		if err != nil {
+			return report, fmt.Errorf("failed to list source indexes: %w", err)
+		}
+		target, err := listIndexes(ctx, tc)
+		if err != nil {
+			return report, fmt.Errorf("failed to list target indexes: %w", err)
+		}
+
+		create, indexes := planIndexes(source, target)
+		for _, index := range create {
+			report.Indexes = append(report.Indexes, index.name)
+		}
+		report.Conflicting = indexes.Conflicting
+	}
+
+	return report, nil
+}
+
+// Preview a copy, recording an error that stopped it in the report
+func (s storage) dryRunResult(ctx context.Context, spec copySpec) *dryRunReport {
+	report, err := s.dryRun(ctx, spec)
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return &report
+}
+
+// Listed specification of a collection, nil if it does not exist
+func collectionSpecification(ctx context.Context, c *mongo.Collection) (*mongo.CollectionSpecification, error) {
+	specs, err := c.Database().ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: c.Name()}})
+	if err != nil || len(specs) == 0 {
+		return nil, err
+	}
+
+	return specs[0], nil
+}
+
+// Average size in bytes of the documents of a collection, zero for an empty or missing collection
+func averageDocumentSize(ctx context.Context, c *mongo.Collection) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{}}}}},
+		{{Key: "$project", Value: bson.D{{Key: "avgObjSize", Value: "$storageStats.avgObjSize"}}}},
+	}
+	cursor, err := c.Aggregate(ctx, pipeline)
+	if isNamespaceNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	// Sharded collections return one result per shard
+	var total, shards int64
+	for cursor.Next(ctx) {
+		var stats struct {
+			AvgObjSize float64 `bson:"avgObjSize"`
+		}
+		if err := cursor.Decode(&stats); err != nil {
+			return 0, err
+		}
+		total += int64(stats.AvgObjSize)
+		shards++
+	}
+	if err := cursor.Err(); err != nil || shards == 0 {
+		return 0, err
+	}
+
+	return total / shards, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:               "0 B",
+		512:             "512 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 30:         "3.0 GiB",
+	}
+	for n, want := range cases {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestDryRunReport_String(t *testing.T) {
+	r := dryRunReport{Deleted: 10, Inserted: 100, Bytes: 2048, Indexes: []string{"code_1"}, Create: true}
+	if s := r.String(); s != "delete 10, insert 100 (2.0 KiB), create 1 indexes, create collection" {
+		t.Errorf("unexpected summary %s", s)
+	}
+
+	r = dryRunReport{Deleted: 10, Inserted: 100, Staged: true}
+	if s := r.String(); s != "delete 10, insert 100 (0 B), swap in staging collection" {
+		t.Errorf("unexpected staged summary %s", s)
+	}
+
+	r = dryRunReport{Error: "no records in source collection to copy"}
+	if s := r.String(); s != "dry run failed: no records in source collection to copy" {
+		t.Errorf("unexpected failed summary %s", s)
+	}
+}
+
+func TestNewDryRunResult(t *testing.T) {
+	spec := copySpec{sourceDatabase: "shop", sourceCollection: "orders", targetDatabase: "archive", targetCollection: "orders"}
+
+	r := newDryRunResult(spec, &dryRunReport{Inserted: 3}, nil)
+	if r.Status != dryRunStatus || r.Error != "" || !r.ok() || r.DryRun.Inserted != 3 {
+		t.Errorf("unexpected dry run result %+v", r)
+	}
+
+	r = newDryRunResult(spec, &dryRunReport{Error: "source collection orders does not exist"}, nil)
+	if r.Status != taskFailed.String() || r.Error == "" || r.ok() {
+		t.Errorf("expected failed dry run result, got %+v", r)
+	}
+}
+
+func TestDryRunCopyTasks(t *testing.T) {
+	m := model{scheduler: newScheduler(1)}
+	m.collectionChoices.copyTasks = []collectionCopyTask{
+		{id: 1, state: taskPending, dryRunReport: &dryRunReport{Inserted: 1}},
+		{id: 2, state: taskSucceeded},
+	}
+
+	cmds := m.dryRunCopyTasks()
+	if len(cmds) != 1 {
+		t.Fatalf("expected only pending tasks to be previewed, got %d", len(cmds))
+	}
+	if !m.collectionChoices.copyTasks[0].previewing || m.collectionChoices.copyTasks[0].dryRunReport != nil {
+		t.Error("expected the previous preview to be replaced")
+	}
+	if cmds := m.dryRunCopyTasks(); len(cmds) != 0 {
+		t.Error("expected tasks being previewed not to be previewed again")
+	}
+}
+
+func TestUpdate_DryRun(t *testing.T) {
+	m := model{keyBindings: keyModel{keys: keys}}
+	m.profileChoices.profilesChosen = true
+	m.databaseChoices.databasesChosen = true
+	m.collectionChoices.altscreen = true
+	m.collectionChoices.copyTasks = []collectionCopyTask{{id: 4, state: taskPending, previewing: true}}
+
+	updated, _ := m.Update(dryRunMsg{collectionId: 4, report: dryRunReport{Deleted: 2}})
+	m = updated.(model)
+	if task := m.collectionChoices.copyTasks[0]; task.previewing || task.dryRunReport == nil || task.dryRunReport.Deleted != 2 {
+		t.Errorf("expected task to hold the preview, got %+v", task.dryRunReport)
+	}
+
+	d := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")}
+	updated, _ = m.Update(d)
+	m = updated.(model)
+	if !m.collectionChoices.dryRun {
+		t.Fatal("expected dry run to be switched on")
+	}
+
+	updated, _ = m.Update(d)
+	m = updated.(model)
+	if m.collectionChoices.dryRun || m.collectionChoices.copyTasks[0].dryRunReport != nil {
+		t.Error("expected dry run to be switched off and previews cleared")
+	}
+}
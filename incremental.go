@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Highest value of the watermark field an incremental copy has copied. Values are extended JSON.
+type watermark struct {
+	Field   string    `json:"field"`
+	Value   string    `json:"value,omitempty"`   // Highest value copied by the last finished copy
+	Pending string    `json:"pending,omitempty"` // Highest value of the copy in progress, which a resumed copy finishes at
+	Updated time.Time `json:"updated"`
+}
+
+// Shown on incremental copies that found nothing to copy
+const noNewDocumentsNotice = "no documents newer than the last copy"
+
+// Check an incremental copy only adds to the target. Sorted or limited copies could leave out documents
+// below the watermark they save, and syncs already follow every change.
+func (spec copySpec) validIncremental() error {
+	if spec.mode != modeUpsert && spec.mode != modeInsertMissing {
+		return errors.New("incremental copies must use upsert or insert-missing mode")
+	} else if spec.query.sort != "" || spec.query.limit > 0 {
+		return errors.New("incremental copies cannot have a sort or limit")
+	} else if spec.sync {
+		return errors.New("incremental copies cannot be synced")
+	}
+
+	return nil
+}
+
+// Narrow the query of an incremental copy to the source documents above the watermark of the last copy,
+// up to the highest value when this copy starts, and save that value as pending. Documents written later
+// with a higher value are left to the next copy. Resumed copies finish at the pending value of the copy
+// that stopped.
+func (s storage) incrementalQuery(ctx context.Context, sc *mongo.Collection, spec copySpec, query parsedQuery) (parsedQuery, error) {
+	saved, last, high, err := s.watermarkBounds(ctx, sc, spec, query)
+	if err != nil {
+		return query, err
+	}
+	query.filter = query.and(watermarkFilter(spec.watermark, last, high))
+
+	if saved.Pending, err = encodeID(high); err != nil {
+		return query, err
+	}
+	return query, s.state.setWatermark(s.copyKey(spec), saved)
+}
+
+// Saved watermark of an incremental copy, the value it was saved at and the highest value the copy
+// goes up to. Either value is unset when there is none, last on a first copy and high when no source
+// documents match the query.
+func (s storage) watermarkBounds(ctx context.Context, sc *mongo.Collection, spec copySpec, query parsedQuery) (saved watermark, last, high bson.RawValue, err error) {
+	// Watermarks of another field say nothing about this one, the copy starts again from the lowest value
+	saved, ok := s.state.watermark(s.copyKey(spec))
+	if !ok || saved.Field != spec.watermark {
+		saved = watermark{Field: spec.watermark}
+	}
+	if last, err = decodeID(saved.Value); err != nil {
+		return saved, last, high, fmt.Errorf("invalid watermark: %w", err)
+	}
+
+	if spec.resume && saved.Pending != "" {
+		if high, err = decodeID(saved.Pending); err != nil {
+			return saved, last, high, fmt.Errorf("invalid watermark: %w", err)
+		}
+		return saved, last, high, nil
+	}
+
+	if high, err = highestValue(ctx, sc, spec.watermark, query.filter); err != nil {
+		return saved, last, high, fmt.Errorf("failed to read highest %s: %w", spec.watermark, err)
+	}
+	return saved, last, high, nil
+}
+
+// Filter of the documents above the last watermark value, if set, up to and including high
+func watermarkFilter(field string, last, high bson.RawValue) bson.D {
+	// Nothing matches when there is no highest value
+	if isEmptyValue(high) {
+		return bson.D{{Key: field, Value: bson.D{{Key: "$in", Value: bson.A{}}}}}
+	}
+
+	bounds := bson.D{{Key: "$lte", Value: high}}
+	if !isEmptyValue(last) {
+		bounds = append(bson.D{{Key: "$gt", Value: last}}, bounds...)
+	}
+	return bson.D{{Key: field, Value: bounds}}
+}
+
+// Save the pending watermark of a finished incremental copy as the highest value copied
+func (s storage) advanceWatermark(spec copySpec) error {
+	if spec.watermark == "" {
+		return nil
+	}
+
+	key := s.copyKey(spec)
+	saved, ok := s.state.watermark(key)
+	if !ok || saved.Pending == "" {
+		return nil
+	}
+
+	saved.Value, saved.Pending = saved.Pending, ""
+	return s.state.setWatermark(key, saved)
+}
+
+// Highest value of a field among the documents matching a filter, unset when no document matches
+func highestValue(ctx context.Context, c *mongo.Collection, field string, filter bson.D) (bson.RawValue, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: field, Value: -1}}).
+		SetProjection(bson.D{{Key: field, Value: 1}})
+
+	doc, err := c.FindOne(ctx, filter, opts).Raw()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return bson.RawValue{}, nil
+	} else if err != nil {
+		return bson.RawValue{}, err
+	}
+
+	// Documents without the field sort last, so none of them have it
+	value, err := doc.LookupErr(strings.Split(field, ".")...)
+	if err != nil {
+		return bson.RawValue{}, fmt.Errorf("no source documents have the watermark field %s", field)
+	}
+
+	return bson.RawValue{Type: value.Type, Value: append([]byte(nil), value.Value...)}, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidIncremental(t *testing.T) {
+	if err := (copySpec{mode: modeUpsert, watermark: "updatedAt"}).validIncremental(); err != nil {
+		t.Errorf("expected upsert incremental copy to be valid, got %v", err)
+	}
+	if err := (copySpec{mode: modeInsertMissing, watermark: "_id", query: copyQuery{filter: `{"tenant":"acme"}`}}).validIncremental(); err != nil {
+		t.Errorf("expected filtered insert-missing incremental copy to be valid, got %v", err)
+	}
+	if err := (copySpec{mode: modeReplace, watermark: "_id"}).validIncremental(); err == nil {
+		t.Error("expected replace incremental copy to be refused")
+	}
+	if err := (copySpec{mode: modeUpsert, watermark: "_id", query: copyQuery{limit: 10}}).validIncremental(); err == nil {
+		t.Error("expected limited incremental copy to be refused")
+	}
+	if err := (copySpec{mode: modeUpsert, watermark: "_id", sync: true}).validIncremental(); err == nil {
+		t.Error("expected synced incremental copy to be refused")
+	}
+}
+
+func TestCopy_IncrementalReplace(t *testing.T) {
+	s := newStorage("mongodb://invalid:1234", "mongodb://invalid:1234")
+	spec := copySpec{sourceDatabase: "shop", sourceCollection: "orders", targetDatabase: "shop", targetCollection: "orders", watermark: "updatedAt"}
+	if _, err := s.copy(context.Background(), spec); err == nil || err.Error() != "incremental copies must use upsert or insert-missing mode" {
+		t.Errorf("expected replace mode error before connecting, got %v", err)
+	}
+}
+
+func TestWatermarkFilter(t *testing.T) {
+	last := int32Value(5)
+	high := int32Value(9)
+
+	got := watermarkFilter("seq", last, high)
+	want := bson.D{{Key: "seq", Value: bson.D{{Key: "$gt", Value: last}, {Key: "$lte", Value: high}}}}
+	if !equalFilters(t, got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	got = watermarkFilter("seq", bson.RawValue{}, high)
+	want = bson.D{{Key: "seq", Value: bson.D{{Key: "$lte", Value: high}}}}
+	if !equalFilters(t, got, want) {
+		t.Errorf("expected first copy to have no lower bound, got %v", got)
+	}
+
+	got = watermarkFilter("seq", last, bson.RawValue{})
+	want = bson.D{{Key: "seq", Value: bson.D{{Key: "$in", Value: bson.A{}}}}}
+	if !equalFilters(t, got, want) {
+		t.Errorf("expected empty source to match nothing, got %v", got)
+	}
+}
+
+func TestWatermarkBounds_Resume(t *testing.T) {
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage("mongodb://target:27017", "mongodb://source:27017").withState(ss)
+	spec := copySpec{sourceDatabase: "shop", sourceCollection: "orders", targetDatabase: "shop", targetCollection: "orders", mode: modeUpsert, watermark: "seq", resume: true}
+	if err := ss.setWatermark(s.copyKey(spec), watermark{Field: "seq", Value: `{"_id":{"$numberInt":"5"}}`, Pending: `{"_id":{"$numberInt":"9"}}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resumed copies finish at the pending value without reading the source
+	_, last, high, err := s.watermarkBounds(context.Background(), nil, spec, parsedQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Int32() != 5 || high.Int32() != 9 {
+		t.Errorf("expected bounds 5 to 9, got %v to %v", last, high)
+	}
+}
+
+func TestAdvanceWatermark(t *testing.T) {
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage("mongodb://target:27017", "mongodb://source:27017").withState(ss)
+	spec := copySpec{sourceDatabase: "shop", sourceCollection: "orders", targetDatabase: "shop", targetCollection: "orders", watermark: "seq"}
+	key := s.copyKey(spec)
+	if err := ss.setWatermark(key, watermark{Field: "seq", Value: `{"_id":{"$numberInt":"5"}}`, Pending: `{"_id":{"$numberInt":"9"}}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.advanceWatermark(spec); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := ss.watermark(key); w.Value != `{"_id":{"$numberInt":"9"}}` || w.Pending != "" {
+		t.Errorf("expected pending value to become the watermark, got %+v", w)
+	}
+}
+
+func TestCopy_Incremental(t *testing.T) {
+	client, uri := replicaSetClient(t)
+	db := client.Database("mongo_move_txn")
+	source := db.Collection("source")
+	seedCollection(t, source, 20, "seq")
+	db.Collection("target").Drop(context.Background())
+
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage(uri, uri).withState(ss)
+	spec := copySpec{sourceDatabase: "mongo_move_txn", sourceCollection: "source", targetDatabase: "mongo_move_txn", targetCollection: "target", mode: modeUpsert, watermark: "seq"}
+	if _, err := s.copy(context.Background(), spec); err != nil {
+		t.Fatalf("expected first incremental copy to succeed, got %v", err)
+	}
+
+	report, err := s.copy(context.Background(), spec)
+	if err != nil || report.notice != noNewDocumentsNotice {
+		t.Fatalf("expected nothing to copy, got %q, %v", report.notice, err)
+	}
+
+	// Only the new documents are read, the changed old one is left behind
+	source.InsertMany(context.Background(), []interface{}{bson.D{{Key: "seq", Value: 20}}, bson.D{{Key: "seq", Value: 21}}})
+	source.UpdateOne(context.Background(), bson.D{{Key: "seq", Value: 0}}, bson.D{{Key: "$set", Value: bson.D{{Key: "changed", Value: true}}}})
+	progress := []copyProgress{}
+	spec.progress = func(p copyProgress) { progress = append(progress, p) }
+	if _, err := s.copy(context.Background(), spec); err != nil {
+		t.Fatalf("expected second incremental copy to succeed, got %v", err)
+	}
+
+	target := db.Collection("target")
+	if count, _ := target.CountDocuments(context.Background(), bson.D{}); count != 22 {
+		t.Errorf("expected 22 target documents, got %d", count)
+	}
+	if changed, _ := target.CountDocuments(context.Background(), bson.D{{Key: "changed", Value: true}}); changed != 0 {
+		t.Errorf("expected documents below the watermark to be skipped, got %d changed", changed)
+	}
+	if len(progress) == 0 || progress[len(progress)-1].total != 2 {
+		t.Errorf("expected 2 documents to copy, got %+v", progress)
+	}
+}
+
+// Compare filters by their encoded BSON
+func equalFilters(t *testing.T, a, b bson.D) bool {
+	t.Helper()
+	ab, err := bson.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb, err := bson.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(ab) == string(bb)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// When indexes of the source collection are recreated on the target collection
+type indexCopy int
+
+const (
+	indexesNone   indexCopy = iota // Indexes are not copied
+	indexesBefore                  // Indexes are created before documents are written
+	indexesAfter                   // Indexes are created once every document has been written
+)
+
+var indexCopies = []indexCopy{indexesNone, indexesBefore, indexesAfter}
+
+func (c indexCopy) String() string {
+	switch c {
+	case indexesNone:
+		return "none"
+	case indexesBefore:
+		return "before"
+	case indexesAfter:
+		return "after"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse index copy from its name, an empty name does not copy indexes
+func parseIndexCopy(name string) (indexCopy, error) {
+	if name == "" {
+		return indexesNone, nil
+	}
+	for _, c := range indexCopies {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+
+	return indexesNone, fmt.Errorf("unknown index copy %q, expected none, before or after", name)
+}
+
+// Next index copy, wrapping around after the last one
+func (c indexCopy) next() indexCopy {
+	return indexCopies[(int(c)+1)%len(indexCopies)]
+}
+
+// Outcome of recreating source indexes on the target collection
+type indexReport struct {
+	Created     []string `json:"created"`     // Indexes created on the target
+	Skipped     []string `json:"skipped"`     // Indexes the target already has with the same key and options
+	Conflicting []string `json:"conflicting"` // Indexes not created because a target index differs, with the reason
+}
+
+func (r indexReport) String() string {
+	return fmt.Sprintf("%d created, %d skipped, %d conflicting", len(r.Created), len(r.Skipped), len(r.Conflicting))
+}
+
+// Check if any index was not copied because of a conflict
+func (r indexReport) conflicts() bool {
+	return len(r.Conflicting) > 0
+}
+
+// Name, key and options of an index, as listed by the server
+type indexSpec struct {
+	name    string
+	key     bson.Raw
+	options bson.Raw // Spec without its name and version, compared to find matching indexes
+	spec    bson.D   // Spec to create the index with
+}
+
+// Read the name, key and options of an index from its listed spec
+func newIndexSpec(raw bson.Raw) (indexSpec, error) {
+	var s indexSpec
+	name, ok := raw.Lookup("name").StringValueOK()
+	if !ok {
+		return s, fmt.Errorf("index has no name: %s", raw)
+	}
+	key, ok := raw.Lookup("key").DocumentOK()
+	if !ok {
+		return s, fmt.Errorf("index %s has no key", name)
+	}
+
+	// Version and namespace are set by the server that creates the index
+	var spec bson.D
+	if err := bson.Unmarshal(raw, &spec); err != nil {
+		return s, err
+	}
+	spec = withoutField(withoutField(spec, "v"), "ns")
+
+	options, err := bson.Marshal(withoutField(spec, "name"))
+	if err != nil {
+		return s, err
+	}
+
+	s.name = name
+	s.key = key
+	s.options = options
+	s.spec = spec
+	return s, nil
+}
+
+// List the indexes of a collection, a collection that does not exist yet has none
+func listIndexes(ctx context.Context, c *mongo.Collection) ([]indexSpec, error) {
+	cursor, err := c.Indexes().List(ctx)
+	if isNamespaceNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var specs []indexSpec
+	for cursor.Next(ctx) {
+		s, err := newIndexSpec(cursor.Current)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, s)
+	}
+
+	return specs, cursor.Err()
+}
+
+// Compare source indexes to target indexes, returning the indexes to create and the report of the rest.
+// An index matches when the target has the same key and options. A target index with the same name
+// or key but different options is a conflict, as the server would refuse to create the source index.
+func planIndexes(source []indexSpec, target []indexSpec) ([]indexSpec, indexReport) {
+	var create []indexSpec
+	report := indexReport{Created: []string{}, Skipped: []string{}, Conflicting: []string{}}
+
+	for _, s := range source {
+		// Every collection has the _id index
+		if s.name == "_id_" {
+			continue
+		}
+
+		conflict := ""
+		skip := false
+		for _, t := range target {
+			sameKey := bytes.Equal(s.key, t.key)
+			if sameKey && bytes.Equal(s.options, t.options) {
+				skip = true
+				break
+			} else if s.name == t.name {
+				conflict = fmt.Sprintf("%s: target index with the same name has a different key or options", s.name)
+			} else if sameKey {
+				conflict = fmt.Sprintf("%s: target index %s has the same key with different options", s.name, t.name)
+			}
+		}
+
+		switch {
+		case skip:
+			report.Skipped = append(report.Skipped, s.name)
+		case conflict != "":
+			report.Conflicting = append(report.Conflicting, conflict)
+		default:
+			create = append(create, s)
+		}
+	}
+
+	return create, report
+}
+
+// Recreate the indexes of the source collection on the target collection.
+// Indexes are created with their listed specs, so unique, TTL, text, partial and other options are kept.
+func (s storage) copyIndexes(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection) (indexReport, error) {
+	source, err := listIndexes(ctx, sc)
+	if err != nil {
+		return indexReport{}, fmt.Errorf("failed to list source indexes: %w", err)
+	}
+	target, err := listIndexes(ctx, tc)
+	if err != nil {
+		return indexReport{}, fmt.Errorf("failed to list target indexes: %w", err)
+	}
+
+	create, report := planIndexes(source, target)
+	for _, index := range create {
+		cmd := bson.D{
+			{Key: "createIndexes", Value: tc.Name()},
+			{Key: "indexes", Value: bson.A{index.spec}},
+		}
+		if err := tc.Database().RunCommand(ctx, cmd).Err(); err != nil {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			report.Conflicting = append(report.Conflicting, fmt.Sprintf("%s: %s", index.name, err))
+			continue
+		}
+		report.Created = append(report.Created, index.name)
+	}
+
+	return report, nil
+}
+
+// Check if a command failed because its collection does not exist
+func isNamespaceNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 26
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func testIndexSpec(t *testing.T, spec bson.D) indexSpec {
+	t.Helper()
+	raw, err := bson.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newIndexSpec(raw)
+	if err != nil {
+		t.Fatalf("failed to read index spec: %v", err)
+	}
+
+	return s
+}
+
+func TestParseIndexCopy(t *testing.T) {
+	for _, c := range indexCopies {
+		parsed, err := parseIndexCopy(c.String())
+		if err != nil || parsed != c {
+			t.Errorf("expected %s, got %s %v", c, parsed, err)
+		}
+	}
+	if c, err := parseIndexCopy(""); err != nil || c != indexesNone {
+		t.Errorf("expected empty name to skip indexes, got %s %v", c, err)
+	}
+	if _, err := parseIndexCopy("during"); err == nil {
+		t.Error("expected error for unknown index copy")
+	}
+	if indexesAfter.next() != indexesNone {
+		t.Error("expected index copy to wrap around")
+	}
+}
+
+func TestNewIndexSpec(t *testing.T) {
+	s := testIndexSpec(t, bson.D{
+		{Key: "v", Value: 2},
+		{Key: "key", Value: bson.D{{Key: "email", Value: 1}}},
+		{Key: "name", Value: "email_1"},
+		{Key: "ns", Value: "shop.customers"},
+		{Key: "unique", Value: true},
+	})
+
+	if s.name != "email_1" {
+		t.Errorf("expected name email_1, got %s", s.name)
+	}
+	for _, e := range s.spec {
+		if e.Key == "v" || e.Key == "ns" {
+			t.Errorf("expected %s to be left out of the spec", e.Key)
+		}
+	}
+	if len(s.spec) != 3 {
+		t.Errorf("expected key, name and unique in spec, got %v", s.spec)
+	}
+}
+
+func TestPlanIndexes(t *testing.T) {
+	id := bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}}
+	email := bson.D{{Key: "key", Value: bson.D{{Key: "email", Value: 1}}}, {Key: "name", Value: "email_1"}, {Key: "unique", Value: true}}
+	ttl := bson.D{{Key: "key", Value: bson.D{{Key: "createdAt", Value: 1}}}, {Key: "name", Value: "createdAt_1"}, {Key: "expireAfterSeconds", Value: 3600}}
+	name := bson.D{{Key: "key", Value: bson.D{{Key: "name", Value: 1}}}, {Key: "name", Value: "name_1"}}
+
+	source := []indexSpec{testIndexSpec(t, id), testIndexSpec(t, email), testIndexSpec(t, ttl), testIndexSpec(t, name)}
+	target := []indexSpec{
+		testIndexSpec(t, id),
+		testIndexSpec(t, email),
+		testIndexSpec(t, bson.D{{Key: "key", Value: bson.D{{Key: "createdAt", Value: 1}}}, {Key: "name", Value: "created"}}),
+	}
+
+	create, report := planIndexes(source, target)
+	if len(create) != 1 || create[0].name != "name_1" {
+		t.Errorf("expected name_1 to be created, got %v", create)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0] != "email_1" {
+		t.Errorf("expected email_1 to be skipped, got %v", report.Skipped)
+	}
+	if len(report.Conflicting) != 1 || !report.conflicts() {
+		t.Errorf("expected createdAt_1 to conflict, got %v", report.Conflicting)
+	}
+}
+
+func TestIndexReportString(t *testing.T) {
+	r := indexReport{Created: []string{"a_1", "b_1"}, Conflicting: []string{"c_1: different options"}}
+	if r.String() != "2 created, 0 skipped, 1 conflicting" {
+		t.Errorf("unexpected report %q", r.String())
+	}
+}
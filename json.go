@@ -13,3 +13,11 @@ func loadJSON[T any](filePath string) (T, error) {
 	}
 	return data, json.Unmarshal(fileData, &data)
 }
+
+func saveJSON[T any](filePath string, data T) error {
+	fileData, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(fileData, '\n'), 0644)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -49,3 +50,18 @@ func TestLoadJSON_InvalidJSON(t *testing.T) {
 		t.Error("expected error for invalid JSON")
 	}
 }
+
+func TestSaveJSON_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	if err := saveJSON(path, testStruct{Name: "Bob", Age: 41}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	result, err := loadJSON[testStruct](path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Name != "Bob" || result.Age != 41 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
@@ -23,6 +23,27 @@ type keyMap struct {
 	StartCopy        key.Binding
 	EditCopyTasks    key.Binding
 	Restart          key.Binding
+	Retry            key.Binding
+	ToggleWriteMode  key.Binding
+	EditWriteKey     key.Binding
+	SavePlan         key.Binding
+	EditFilter       key.Binding
+	Resume           key.Binding
+	CancelTask       key.Binding
+	CancelAll        key.Binding
+	TogglePause      key.Binding
+	ToggleIndexes    key.Binding
+	NewTarget        key.Binding
+	CopyDatabase     key.Binding
+	AutoMap          key.Binding
+	Verify           key.Binding
+	DryRun           key.Binding
+	ToggleBackup     key.Binding
+	ToggleStaging    key.Binding
+	ToggleTxn        key.Binding
+	ToggleSync       key.Binding
+	EditWatermark    key.Binding
+	PreviewTransform key.Binding
 }
 
 type keyModel struct {
@@ -91,6 +112,90 @@ var keys = keyMap{
 		key.WithKeys("r"),
 		key.WithHelp("r", "restart"),
 	),
+	Retry: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "retry failed or cancelled"),
+	),
+	ToggleWriteMode: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "write mode"),
+	),
+	EditWriteKey: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "write key"),
+	),
+	EditFilter: key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("f", "filter documents"),
+	),
+	SavePlan: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "save plan"),
+	),
+	Resume: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "resume from checkpoint"),
+	),
+	CancelTask: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "cancel copy"),
+	),
+	CancelAll: key.NewBinding(
+		key.WithKeys("X"),
+		key.WithHelp("X", "cancel all copies"),
+	),
+	TogglePause: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pause/continue copy"),
+	),
+	ToggleIndexes: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "copy indexes"),
+	),
+	NewTarget: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "new target collection"),
+	),
+	CopyDatabase: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "copy whole database"),
+	),
+	AutoMap: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "auto-map by name"),
+	),
+	Verify: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "verify copy"),
+	),
+	DryRun: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "dry run on/off"),
+	),
+	ToggleBackup: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "backup mode"),
+	),
+	ToggleStaging: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "staged replace on/off"),
+	),
+	ToggleTxn: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "transaction on/off"),
+	),
+	ToggleSync: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "sync changes on/off"),
+	),
+	EditWatermark: key.NewBinding(
+		key.WithKeys("W"),
+		key.WithHelp("W", "incremental copy field"),
+	),
+	PreviewTransform: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "preview transformed document"),
+	),
 }
 
 func (m model) databaseChoicesHelp() string {
@@ -108,6 +213,7 @@ func (m model) databaseChoicesHelp() string {
 		subtleStyle.Render(m.keyBindings.keys.FilterQuit.Help().Key+seperator+m.keyBindings.keys.FilterQuit.Help().Desc) + "\n"
 
 	other := highlight.Render(m.keyBindings.keys.Select.Help().Key+seperator+m.keyBindings.keys.Select.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.CopyDatabase.Help().Key+seperator+m.keyBindings.keys.CopyDatabase.Help().Desc) + "\n" +
 		subtleStyle.Render(m.keyBindings.keys.Quit.Help().Key+seperator+m.keyBindings.keys.Quit.Help().Desc) + "\n"
 
 	help := []string{
@@ -135,6 +241,8 @@ func (m model) collectionChoicesHelp() string {
 
 	copy := highlight.Render(m.keyBindings.keys.ToggleAltView.Help().Key+seperator+"view selections") + "\n" +
 		highlight.Render(m.keyBindings.keys.Select.Help().Key+seperator+m.keyBindings.keys.Select.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.NewTarget.Help().Key+seperator+m.keyBindings.keys.NewTarget.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.AutoMap.Help().Key+seperator+m.keyBindings.keys.AutoMap.Help().Desc) + "\n" +
 		"\n" +
 		subtleStyle.Render(m.keyBindings.keys.Quit.Help().Key+seperator+m.keyBindings.keys.Quit.Help().Desc) + "\n"
 
@@ -163,14 +271,36 @@ func (m model) collectionChoicesCopyHelp() string {
 
 	copy := highlight.Render(m.keyBindings.keys.ToggleAltView.Help().Key+seperator+"view collections") + "\n" +
 		highlight.Render(m.keyBindings.keys.Select.Help().Key+seperator+"remove") + "\n" +
+		highlight.Render(m.keyBindings.keys.ToggleWriteMode.Help().Key+seperator+m.keyBindings.keys.ToggleWriteMode.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.EditWriteKey.Help().Key+seperator+m.keyBindings.keys.EditWriteKey.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.EditFilter.Help().Key+seperator+m.keyBindings.keys.EditFilter.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.ToggleIndexes.Help().Key+seperator+m.keyBindings.keys.ToggleIndexes.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.ToggleStaging.Help().Key+seperator+m.keyBindings.keys.ToggleStaging.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.ToggleTxn.Help().Key+seperator+m.keyBindings.keys.ToggleTxn.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.ToggleSync.Help().Key+seperator+m.keyBindings.keys.ToggleSync.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.EditWatermark.Help().Key+seperator+m.keyBindings.keys.EditWatermark.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.PreviewTransform.Help().Key+seperator+m.keyBindings.keys.PreviewTransform.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.Resume.Help().Key+seperator+m.keyBindings.keys.Resume.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.SavePlan.Help().Key+seperator+m.keyBindings.keys.SavePlan.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.DryRun.Help().Key+seperator+m.keyBindings.keys.DryRun.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.ToggleBackup.Help().Key+seperator+m.keyBindings.keys.ToggleBackup.Help().Desc) + "\n" +
 		highlight.Render(m.keyBindings.keys.StartCopy.Help().Key+seperator+m.keyBindings.keys.StartCopy.Help().Desc) + "\n" +
 		subtleStyle.Render(m.keyBindings.keys.Quit.Help().Key+seperator+m.keyBindings.keys.Quit.Help().Desc) + "\n"
 
+	running := highlight.Render(m.keyBindings.keys.TogglePause.Help().Key+seperator+m.keyBindings.keys.TogglePause.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.CancelTask.Help().Key+seperator+m.keyBindings.keys.CancelTask.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.CancelAll.Help().Key+seperator+m.keyBindings.keys.CancelAll.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.Retry.Help().Key+seperator+m.keyBindings.keys.Retry.Help().Desc) + "\n" +
+		highlight.Render(m.keyBindings.keys.Verify.Help().Key+seperator+m.keyBindings.keys.Verify.Help().Desc) + "\n"
+
 	help := []string{
 		lipgloss.JoinVertical(lipgloss.Center, pad.Render(navigation)),
 		lipgloss.JoinVertical(lipgloss.Center, pad.Render(table)),
 		lipgloss.JoinVertical(lipgloss.Center, pad.Render(copy)),
 	}
+	if m.collectionChoices.CopyStarted {
+		help = append(help, lipgloss.JoinVertical(lipgloss.Center, pad.Render(running)))
+	}
 
 	return lipgloss.JoinHorizontal(lipgloss.Top, help...)
 }
@@ -181,6 +311,12 @@ func (m model) RestartHelp() string {
 
 	quit := subtleStyle.Render(m.keyBindings.keys.Quit.Help().Key+seperator+m.keyBindings.keys.Quit.Help().Desc) + "\n"
 	restart := subtleStyle.Render(m.keyBindings.keys.Restart.Help().Key+seperator+m.keyBindings.keys.Restart.Help().Desc) + "\n"
+	highlight := lipgloss.NewStyle().Foreground(lipgloss.Color("#54ad48"))
+	restart += highlight.Render(m.keyBindings.keys.Verify.Help().Key+seperator+m.keyBindings.keys.Verify.Help().Desc) + "\n"
+	if m.hasFailedTasks() || m.hasCancelledTasks() {
+		restart += highlight.Render(m.keyBindings.keys.Retry.Help().Key+seperator+m.keyBindings.keys.Retry.Help().Desc) + "\n"
+		restart += highlight.Render(m.keyBindings.keys.Resume.Help().Key+seperator+m.keyBindings.keys.Resume.Help().Desc) + "\n"
+	}
 	help := []string{
 		lipgloss.JoinVertical(lipgloss.Center, pad.Render(restart)),
 		lipgloss.JoinVertical(lipgloss.Center, pad.Render(quit)),
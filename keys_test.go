@@ -2,6 +2,10 @@ package main
 
 import (
 	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/evertras/bubble-table/table"
 )
 
 func TestDatabaseChoicesHelp(t *testing.T) {
@@ -35,3 +39,48 @@ func TestRestartHelp(t *testing.T) {
 		t.Error("RestartHelp should return non-empty string")
 	}
 }
+
+func TestCopyTaskKeys_TableDefaults(t *testing.T) {
+	tk := table.DefaultKeyMap()
+	tableKeys := map[string]bool{}
+	for _, b := range []key.Binding{tk.RowDown, tk.RowUp, tk.PageDown, tk.PageUp, tk.PageFirst, tk.PageLast, tk.Filter} {
+		for _, k := range b.Keys() {
+			tableKeys[k] = true
+		}
+	}
+
+	// Task keys the table would also act on
+	for _, b := range []key.Binding{keys.ToggleWriteMode, keys.EditWriteKey, keys.EditFilter, keys.CancelTask, keys.CancelAll, keys.TogglePause, keys.ToggleIndexes, keys.Verify, keys.DryRun, keys.ToggleBackup, keys.ToggleStaging, keys.ToggleTxn, keys.ToggleSync, keys.EditWatermark, keys.PreviewTransform} {
+		for _, k := range b.Keys() {
+			if tableKeys[k] {
+				t.Errorf("expected %s (%s) not to be a table key", k, b.Help().Desc)
+			}
+		}
+	}
+}
+
+func TestUpdate_TaskKeysInFilter(t *testing.T) {
+	m := model{keyBindings: keyModel{keys: keys}}
+	m.profileChoices.profilesChosen = true
+	m.databaseChoices.databasesChosen = true
+	m.collectionChoices.altscreen = true
+	m.collectionChoices.CopyStarted = true
+	m.collectionChoices.copyTaskTable = buildTable([]table.Column{
+		table.NewColumn(sourceCollectionsColumnName, sourceCollectionsColumnName, 25).WithFiltered(true),
+	}).Focused(true)
+	cancelled := false
+	m.collectionChoices.copyTasks = []collectionCopyTask{{id: 1, state: taskRunning, cancel: func() { cancelled = true }, pause: newPauser()}}
+	m.buildCollectionMapRows()
+
+	for _, k := range []string{"/", "x", "X", "p", "m"} {
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = updated.(model)
+	}
+
+	if got := m.collectionChoices.copyTaskTable.GetCurrentFilter(); got != "xXpm" {
+		t.Errorf("expected letters typed into the filter, got %q", got)
+	}
+	if task := m.collectionChoices.copyTasks[0]; task.state != taskRunning || cancelled {
+		t.Errorf("expected task keys to be ignored while filtering, got state %v, cancelled %v", task.state, cancelled)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"time"
@@ -15,6 +16,10 @@ import (
 )
 
 func main() {
+	planPath := flag.String("plan", "", "load a saved copy plan into the terminal UI")
+	sourceProfile := flag.String("source", "", "name of the config profile to copy from")
+	targetProfile := flag.String("target", "", "name of the config profile to copy to")
+	flag.Parse()
 
 	// Load and validate config
 	config, err := load()
@@ -27,9 +32,27 @@ func main() {
 		fmt.Println(err)
 		os.Exit(exit.FromError(err))
 	}
+	config, err = config.useProfiles(*sourceProfile, *targetProfile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exit.UsageError)
+	}
+
+	// Run headless when a subcommand is given, otherwise start the terminal UI
+	if flag.NArg() > 0 {
+		err = runCLI(flag.Args(), config, os.Stdout)
+		if cause := exitCause(err); cause != nil {
+			fmt.Fprintln(os.Stderr, cause)
+		}
+		os.Exit(exit.FromError(err))
+	}
 
 	// Set up storage
-	var s = newStorage(config.Target, config.Source)
+	s, err := newStorageFromConfig(config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exit.FromError(err))
+	}
 
 	var cctvm collectionChoicesViewModel
 	cctvm.rowCount = 10
@@ -50,7 +73,12 @@ func main() {
 	cctvm.copyTaskTable = buildTable([]table.Column{
 		table.NewColumn(sourceCollectionsColumnName, sourceCollectionsColumnName, 25).WithFiltered(true),
 		table.NewColumn(targetCollectionsColumnName, targetCollectionsColumnName, 25).WithFiltered(true),
-		table.NewColumn(CopyStatusColumnName, CopyStatusColumnName, 15),
+		table.NewColumn(writeModeColumnName, writeModeColumnName, 22),
+		table.NewColumn(queryColumnName, queryColumnName, 30),
+		table.NewColumn(indexesColumnName, indexesColumnName, 34),
+		table.NewColumn(progressColumnName, progressColumnName, progressBarWidth),
+		table.NewColumn(verifyColumnName, verifyColumnName, 34),
+		table.NewColumn(CopyStatusColumnName, CopyStatusColumnName, 40),
 	}).
 		WithPageSize(cctvm.pageSize).
 		Focused(false)
@@ -58,6 +86,7 @@ func main() {
 	cctvm.currentCopyTask = collectionCopyTask{}
 	cctvm.debounce = 2 * time.Second
 	cctvm.altscreen = false
+	cctvm.backup, _ = parseBackupMode(config.Backup)
 
 	var dcvm databaseChoicesViewModel
 	dcvm.sourceDatabases = []string{}
@@ -86,6 +115,8 @@ func main() {
 	dcvm.databasesLoaded = false
 	dcvm.debounce = 2 * time.Second
 
+	pcvm := newProfileChoices(config, *sourceProfile, *targetProfile)
+
 	var keyModel keyModel
 	keyModel.quitting = false
 	keyModel.keys = keys
@@ -111,15 +142,61 @@ func main() {
 
 	// Load terminal UI with intital model
 	initialModel := model{
+		profileChoices:    pcvm,
 		databaseChoices:   dcvm,
 		keyBindings:       keyModel,
 		storage:           s,
 		collectionChoices: cctvm,
 		spinner:           sp,
+		scheduler:         newScheduler(config.MaxConcurrentCopies),
+		protection:        config.protection(),
+	}
+
+	// Skip choosing databases when starting from a saved plan
+	if *planPath != "" {
+		plan, err := loadPlan(*planPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exit.UsageError)
+		}
+		initialModel.plan = &plan
+		initialModel.storage, err = plan.storage(s, config)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exit.UsageError)
+		}
+		initialModel.databaseChoices.sourceDatabaseChoice = plan.Source.Database
+		initialModel.databaseChoices.targetDatabaseChoice = plan.Target.Database
+		initialModel.databaseChoices.databasesChosen = true
+		initialModel.databaseChoices.databasesLoaded = true
+		initialModel.profileChoices.profilesChosen = true
+	}
+	initialModel.buildProfileTableRows()
+
+	// Servers chosen on the profile view are connected to once chosen
+	if initialModel.profileChoices.profilesChosen {
+		initialModel.storage, err = initialModel.storage.connect()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exit.UsageError)
+		}
 	}
 
 	p := tea.NewProgram(initialModel)
-	if _, err := p.Run(); err != nil {
+	finalModel, err := p.Run()
+	if err != nil {
 		fmt.Println("could not start program:", err)
+		os.Exit(exit.InternalError)
+	}
+
+	m, ok := finalModel.(model)
+	if !ok {
+		return
+	}
+	m.shutdown()
+
+	// Exit with a non-zero code if any collection failed to copy
+	if m.hasFailedTasks() {
+		os.Exit(exit.NotOK)
 	}
 }
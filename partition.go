@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultPartitionWorkers      = 1      // Collections are copied by a single worker unless set in config
+	defaultPartitionMinDocuments = 100000 // Smallest collection that is split between workers
+)
+
+// Check every document in the source collection matching the filter has the same _id type, so documents
+// can be read in _id order and range queries on _id match all of them
+func (s storage) uniformIDs(ctx context.Context, sc *mongo.Collection, filter bson.D) (bool, error) {
+	first, err := s.idAt(ctx, sc, filter, 1, 0)
+	if err != nil {
+		return false, err
+	}
+	last, err := s.idAt(ctx, sc, filter, -1, 0)
+	if err != nil {
+		return false, err
+	}
+
+	return first.Type == last.Type, nil
+}
+
+// Find the _id values that split the count source documents matching the filter into the given number of
+// roughly equal ranges. Range queries only match _id values of the same BSON type, so no bounds are returned
+// when the documents mix _id types and they should be copied by a single worker.
+func (s storage) partitionBounds(ctx context.Context, sc *mongo.Collection, filter bson.D, count int64, partitions int) ([]bson.RawValue, error) {
+	uniform, err := s.uniformIDs(ctx, sc, filter)
+	if err != nil || !uniform {
+		return nil, err
+	}
+	first, err := s.idAt(ctx, sc, filter, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var bounds []bson.RawValue
+	for i := 1; i < partitions; i++ {
+		bound, err := s.idAt(ctx, sc, filter, 1, count*int64(i)/int64(partitions))
+		if err != nil {
+			return nil, err
+		}
+		if bound.Type != first.Type {
+			return nil, nil
+		}
+		if len(bounds) > 0 && bounds[len(bounds)-1].Equal(bound) {
+			continue
+		}
+		bounds = append(bounds, bound)
+	}
+
+	return bounds, nil
+}
+
+// Get the _id of the document matching the filter at position skip when sorted by _id in the given direction
+func (s storage) idAt(ctx context.Context, sc *mongo.Collection, filter bson.D, direction int, skip int64) (bson.RawValue, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "_id", Value: direction}}).
+		SetSkip(skip).
+		SetProjection(bson.D{{Key: "_id", Value: 1}})
+
+	raw, err := sc.FindOne(ctx, filter, opts).Raw()
+	if err != nil {
+		return bson.RawValue{}, err
+	}
+
+	return raw.Lookup("_id"), nil
+}
+
+// Range of _id values copied by one worker, open ended where a bound is not set
+type idRange struct {
+	min bson.RawValue // Inclusive lower bound
+	max bson.RawValue // Exclusive upper bound
+}
+
+// Split the _id values into ranges between the given bounds.
+// The first and last ranges are open ended so every document falls in exactly one range.
+func partitionRanges(bounds []bson.RawValue) []idRange {
+	ranges := make([]idRange, 0, len(bounds)+1)
+
+	var min bson.RawValue
+	for _, bound := range bounds {
+		ranges = append(ranges, idRange{min: min, max: bound})
+		min = bound
+	}
+
+	return append(ranges, idRange{min: min})
+}
+
+// Query filter matching the _id values in the range that come after the last one copied, if set
+func (r idRange) filter(last bson.RawValue) bson.D {
+	var conditions bson.D
+	if !isEmptyValue(last) {
+		conditions = append(conditions, bson.E{Key: "$gt", Value: last})
+	} else if !isEmptyValue(r.min) {
+		conditions = append(conditions, bson.E{Key: "$gte", Value: r.min})
+	}
+	if !isEmptyValue(r.max) {
+		conditions = append(conditions, bson.E{Key: "$lt", Value: r.max})
+	}
+
+	if len(conditions) == 0 {
+		return bson.D{}
+	}
+
+	return bson.D{{Key: "_id", Value: conditions}}
+}
+
+// Check if a value has not been set
+func isEmptyValue(v bson.RawValue) bool {
+	return v.Type == 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+func int32Value(i int32) bson.RawValue {
+	return bson.RawValue{Type: bsontype.Int32, Value: []byte{byte(i), byte(i >> 8), byte(i >> 16), byte(i >> 24)}}
+}
+
+func TestPartitionRanges_NoBounds(t *testing.T) {
+	ranges := partitionRanges(nil)
+	if len(ranges) != 1 {
+		t.Fatalf("expected a single range, got %d", len(ranges))
+	}
+	if filter := ranges[0].filter(bson.RawValue{}); len(filter) != 0 {
+		t.Errorf("expected empty filter, got %v", filter)
+	}
+}
+
+func TestPartitionRanges_Filters(t *testing.T) {
+	ranges := partitionRanges([]bson.RawValue{int32Value(10), int32Value(20)})
+	if len(ranges) != 3 {
+		t.Fatalf("expected 3 ranges, got %d", len(ranges))
+	}
+
+	first := ranges[0].filter(bson.RawValue{})[0].Value.(bson.D)
+	if len(first) != 1 || first[0].Key != "$lt" {
+		t.Errorf("expected first range to be open at the start, got %v", first)
+	}
+	middle := ranges[1].filter(bson.RawValue{})[0].Value.(bson.D)
+	if len(middle) != 2 || middle[0].Key != "$gte" || middle[1].Key != "$lt" {
+		t.Errorf("expected middle range to be bounded, got %v", middle)
+	}
+	last := ranges[2].filter(bson.RawValue{})[0].Value.(bson.D)
+	if len(last) != 1 || last[0].Key != "$gte" {
+		t.Errorf("expected last range to be open at the end, got %v", last)
+	}
+}
+
+func TestIDRangeFilter_AfterLast(t *testing.T) {
+	r := idRange{min: int32Value(10), max: int32Value(20)}
+	conditions := r.filter(int32Value(15))[0].Value.(bson.D)
+	if len(conditions) != 2 || conditions[0].Key != "$gt" || !conditions[0].Value.(bson.RawValue).Equal(int32Value(15)) {
+		t.Errorf("expected range to start after the last copied _id, got %v", conditions)
+	}
+}
+
+func TestPartitionBounds_Filter(t *testing.T) {
+	client, uri := replicaSetClient(t)
+	source := client.Database("mongo_move_txn").Collection("source")
+	seedCollection(t, source, 100, "n")
+
+	// Bounds split the 50 matching documents, not the whole collection
+	filter := bson.D{{Key: "n", Value: bson.D{{Key: "$gte", Value: 50}}}}
+	bounds, err := newStorage(uri, uri).partitionBounds(context.Background(), source, filter, 50, 2)
+	if err != nil || len(bounds) != 1 {
+		t.Fatalf("expected one bound, got %v, %v", bounds, err)
+	}
+
+	var doc struct{ N int }
+	if err := source.FindOne(context.Background(), bson.D{{Key: "_id", Value: bounds[0]}}).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.N != 75 {
+		t.Errorf("expected bound at n 75, got %d", doc.N)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Holds a running copy between bulk writes while it is paused. A nil pauser never pauses.
+type pauser struct {
+	mu      sync.Mutex
+	resumed chan struct{} // Closed when the copy is resumed, nil while not paused
+}
+
+func newPauser() *pauser {
+	return &pauser{}
+}
+
+// Hold the copy before its next bulk write
+func (p *pauser) pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+	}
+}
+
+// Let the copy continue
+func (p *pauser) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
+}
+
+// Check if the copy is paused
+func (p *pauser) paused() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resumed != nil
+}
+
+// Block while the copy is paused, returning early with the context's error if it is cancelled
+func (p *pauser) wait(ctx context.Context) error {
+	if p != nil {
+		p.mu.Lock()
+		resumed := p.resumed
+		p.mu.Unlock()
+
+		if resumed != nil {
+			select {
+			case <-resumed:
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	return ctx.Err()
+}
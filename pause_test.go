@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPauser_Wait(t *testing.T) {
+	p := newPauser()
+	if err := p.wait(context.Background()); err != nil {
+		t.Fatalf("expected running copy not to wait, got %v", err)
+	}
+
+	p.pause()
+	if !p.paused() {
+		t.Fatal("expected copy to be paused")
+	}
+
+	done := make(chan error)
+	go func() { done <- p.wait(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("expected paused copy to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	p.resume()
+	if err := <-done; err != nil {
+		t.Errorf("expected resumed copy to continue, got %v", err)
+	}
+}
+
+func TestPauser_Cancelled(t *testing.T) {
+	p := newPauser()
+	p.pause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancelled error, got %v", err)
+	}
+}
+
+func TestPauser_Nil(t *testing.T) {
+	var p *pauser
+	if p.paused() || p.wait(context.Background()) != nil {
+		t.Error("expected nil pauser never to pause")
+	}
+}
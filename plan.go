@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const planVersion = 1 // Version written to new plan files
+
+// Saved selection of collections to copy that can be loaded into the terminal UI or run headless
+type plan struct {
+	Version int        `json:"version"`
+	Source  planServer `json:"source"`
+	Target  planServer `json:"target"`
+	Tasks   []planTask `json:"tasks"`
+}
+
+// Server and database copied from or to
+type planServer struct {
+	Profile  string `json:"profile,omitempty"` // Config profile to connect with
+	Server   string `json:"server,omitempty"`  // Connection string, used when no profile is set
+	Database string `json:"database"`
+}
+
+// Source and target collection pair of a plan
+type planTask struct {
+	Source     string          `json:"source"`
+	Target     string          `json:"target"`
+	Mode       string          `json:"mode,omitempty"`
+	Key        string          `json:"key,omitempty"`
+	Filter     json.RawMessage `json:"filter,omitempty"`     // Extended JSON query filter
+	Sort       json.RawMessage `json:"sort,omitempty"`       // Extended JSON sort document
+	Projection json.RawMessage `json:"projection,omitempty"` // Extended JSON projection document
+	Limit      int64           `json:"limit,omitempty"`
+	Indexes    string          `json:"indexes,omitempty"` // When source indexes are copied: none, before or after
+	Create     bool            `json:"create,omitempty"`  // Create the target collection with the source options
+	Staging    bool            `json:"staging,omitempty"` // Load a staging collection that replaces the target, replace mode only
+
+	Transaction bool `json:"transaction,omitempty"` // Delete and write the target documents in one transaction
+	Sync        bool `json:"sync,omitempty"`        // Keep applying source changes to the target after the copy
+
+	Watermark string            `json:"watermark,omitempty"` // Increasing field, only documents above the last copy's highest value are copied
+	Transform transformPipeline `json:"transform,omitempty"` // Steps applied to every source document before it is written
+}
+
+// Subset of source documents the task copies
+func (t planTask) query() copyQuery {
+	return copyQuery{
+		filter:     string(t.Filter),
+		sort:       string(t.Sort),
+		projection: string(t.Projection),
+		limit:      t.Limit,
+	}
+}
+
+// Load and validate plan file
+func loadPlan(filePath string) (plan, error) {
+	p, err := loadJSON[plan](filePath)
+	if err != nil {
+		return p, err
+	}
+
+	return p, p.validate()
+}
+
+// Write plan file
+func savePlan(filePath string, p plan) error {
+	p.Version = planVersion
+	return saveJSON(filePath, p)
+}
+
+func (p plan) validate() error {
+	if p.Version != planVersion {
+		return fmt.Errorf("unsupported plan version %d, expected %d", p.Version, planVersion)
+	} else if p.Source.Database == "" {
+		return errors.New("plan value \"source.database\" is missing")
+	} else if p.Target.Database == "" {
+		return errors.New("plan value \"target.database\" is missing")
+	} else if len(p.Tasks) == 0 {
+		return errors.New("plan has no tasks")
+	}
+
+	for i, t := range p.Tasks {
+		if t.Source == "" || t.Target == "" {
+			return fmt.Errorf("plan task %d is missing a source or target collection", i+1)
+		}
+		if _, err := parseWriteMode(t.Mode); err != nil {
+			return fmt.Errorf("plan task %d: %w", i+1, err)
+		}
+		if _, err := t.query().parse(); err != nil {
+			return fmt.Errorf("plan task %d: %w", i+1, err)
+		}
+		if _, err := parseIndexCopy(t.Indexes); err != nil {
+			return fmt.Errorf("plan task %d: %w", i+1, err)
+		}
+		if _, err := t.Transform.parse(); err != nil {
+			return fmt.Errorf("plan task %d: %w", i+1, err)
+		}
+		if mode, _ := parseWriteMode(t.Mode); t.Staging && mode != modeReplace {
+			return fmt.Errorf("plan task %d: staged copies only work in replace mode", i+1)
+		}
+		if t.Staging && t.Transaction {
+			return fmt.Errorf("plan task %d: transactional copies cannot be staged", i+1)
+		}
+		if t.Sync {
+			spec := copySpec{query: t.query(), key: t.Key, transform: t.Transform}
+			spec.mode, _ = parseWriteMode(t.Mode)
+			if err := spec.validSync(); err != nil {
+				return fmt.Errorf("plan task %d: %w", i+1, err)
+			}
+		}
+		if t.Watermark != "" {
+			spec := copySpec{query: t.query(), sync: t.Sync}
+			spec.mode, _ = parseWriteMode(t.Mode)
+			if err := spec.validIncremental(); err != nil {
+				return fmt.Errorf("plan task %d: %w", i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Use the servers in the plan in place of the ones the storage was created with.
+// Servers are taken from the plan profile, then the plan server, then the storage.
+func (p plan) storage(s storage, c config) (storage, error) {
+	source, err := p.Source.uri(c, s.sourceURI)
+	if err != nil {
+		return s, err
+	}
+	target, err := p.Target.uri(c, s.targetURI)
+	if err != nil {
+		return s, err
+	}
+	if source == "" || target == "" {
+		return s, errors.New("plan needs a source and target \"profile\" or \"server\", or profiles chosen with -source and -target")
+	}
+
+	return s.withServers(target, source), nil
+}
+
+// Connection string of the server, falling back to the given one when the plan does not set it
+func (ps planServer) uri(c config, fallback string) (string, error) {
+	if ps.Profile != "" {
+		p, err := c.profile(ps.Profile)
+		return p.Server, err
+	} else if ps.Server != "" {
+		return ps.Server, nil
+	}
+
+	return fallback, nil
+}
+
+// Details of the copy to run for a plan task
+func (p plan) copySpec(t planTask) copySpec {
+	mode, _ := parseWriteMode(t.Mode)
+	indexes, _ := parseIndexCopy(t.Indexes)
+	return copySpec{
+		sourceDatabase:   p.Source.Database,
+		sourceCollection: t.Source,
+		targetDatabase:   p.Target.Database,
+		targetCollection: t.Target,
+		mode:             mode,
+		key:              t.Key,
+		query:            t.query(),
+		indexes:          indexes,
+		create:           t.Create,
+		staging:          t.Staging,
+		transaction:      t.Transaction,
+		sync:             t.Sync,
+		watermark:        t.Watermark,
+		transform:        t.Transform,
+	}
+}
+
+// How a plan is run from the command line
+type planRunOptions struct {
+	resume bool       // Tasks that stopped part way continue from their checkpoint
+	verify bool       // Each copied target is compared with its source
+	dryRun bool       // Tasks report what they would do without writing anything
+	backup backupMode // Where target documents are saved before a task deletes them
+}
+
+// Copy every task in the plan, running as many at once as the scheduler allows.
+// Results are returned in the same order as the plan tasks.
+func (p plan) run(ctx context.Context, s storage, sched *scheduler, opts planRunOptions) []copyResult {
+	results := make([]copyResult, len(p.Tasks))
+
+	var wg sync.WaitGroup
+	for i, t := range p.Tasks {
+		wg.Add(1)
+		go func(i int, t planTask) {
+			defer wg.Done()
+			spec := p.copySpec(t)
+			spec.resume = opts.resume && !spec.transaction && s.resumable(spec)
+			spec.backup = opts.backup
+			if opts.dryRun {
+				var preview *dryRunReport
+				err := sched.run(ctx, func() error {
+					preview = s.dryRunResult(ctx, spec)
+					return nil
+				})
+				results[i] = newDryRunResult(spec, preview, err)
+				return
+			}
+
+			var report copyReport
+			var verified *verifyReport
+			if spec.sync {
+				// Syncs run until the plan run is interrupted, only their initial copy takes a slot
+				var err error
+				report, err = s.sync(ctx, spec, func(initial func() error) error {
+					return sched.run(ctx, initial)
+				})
+				results[i] = newCopyResult(spec, report, err)
+				return
+			}
+			err := sched.run(ctx, func() error {
+				var err error
+				if report, err = s.copy(ctx, spec); err != nil || !opts.verify {
+					return err
+				}
+				verified = s.verifyResult(ctx, spec)
+				return nil
+			})
+			results[i] = newCopyResult(spec, report, err)
+			results[i].Verify = verified
+		}(i, t)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Servers of a plan set by connection string rather than profile, which are saved without credentials
+func (p plan) serversWithoutProfile() []string {
+	servers := []string{}
+	if p.Source.Profile == "" {
+		servers = append(servers, "source")
+	}
+	if p.Target.Profile == "" {
+		servers = append(servers, "target")
+	}
+	return servers
+}
+
+// Build a plan from the copy tasks chosen in the terminal UI
+func (m model) exportPlan() plan {
+	p := plan{
+		Version: planVersion,
+		Source:  planServer{Database: m.databaseChoices.sourceDatabaseChoice},
+		Target:  planServer{Database: m.databaseChoices.targetDatabaseChoice},
+		Tasks:   []planTask{},
+	}
+
+	// Refer to servers by profile name where one was chosen so connection strings stay in config.
+	// Plans are shared, so credentials are never written to them.
+	if m.profileChoices.sourceProfileChoice != "" {
+		p.Source.Profile = m.profileChoices.sourceProfileChoice
+	} else {
+		p.Source.Server = withoutCredentials(m.storage.sourceURI)
+	}
+	if m.profileChoices.targetProfileChoice != "" {
+		p.Target.Profile = m.profileChoices.targetProfileChoice
+	} else {
+		p.Target.Server = withoutCredentials(m.storage.targetURI)
+	}
+
+	for _, t := range m.collectionChoices.copyTasks {
+		pt := planTask{
+			Source:     t.source.name,
+			Target:     t.target.name,
+			Mode:       t.mode.String(),
+			Filter:     rawExtJSON(t.query.filter),
+			Sort:       rawExtJSON(t.query.sort),
+			Projection: rawExtJSON(t.query.projection),
+			Limit:      t.query.limit,
+		}
+		if t.mode.keyed() {
+			pt.Key = t.writeKey()
+		}
+		if t.indexes != indexesNone {
+			pt.Indexes = t.indexes.String()
+		}
+		pt.Create = t.target.create
+		pt.Staging = t.staging
+		pt.Transaction = t.transaction
+		pt.Sync = t.sync
+		pt.Watermark = t.watermark
+		pt.Transform = t.transform
+		p.Tasks = append(p.Tasks, pt)
+	}
+
+	return p
+}
+
+// Turn the tasks of the plan loaded at startup into copy tasks, taking their collections out of the
+// source and target tables. Returns an error naming any collections that could not be found.
+func (m *model) applyPlan() error {
+	var missing []string
+
+	for _, t := range m.plan.Tasks {
+		si := collectionIndex(m.databaseChoices.sourceCollections, t.Source)
+		ti := collectionIndex(m.databaseChoices.targetCollections, t.Target)
+		if si < 0 {
+			missing = append(missing, m.plan.Source.Database+"."+t.Source)
+		}
+		if ti < 0 && !t.Create {
+			missing = append(missing, m.plan.Target.Database+"."+t.Target)
+		}
+		if si < 0 || (ti < 0 && !t.Create) {
+			continue
+		}
+
+		// Targets the plan creates may not exist yet
+		target := collection{name: t.Target, create: t.Create}
+		if ti >= 0 {
+			target = m.databaseChoices.targetCollections[ti]
+			target.create = t.Create
+			m.databaseChoices.targetCollections = removeItem(m.databaseChoices.targetCollections, ti)
+		}
+
+		task := newCollectionCopyTask(m.databaseChoices.sourceCollections[si], target)
+		task.mode, _ = parseWriteMode(t.Mode)
+		task.key = t.Key
+		task.query = t.query()
+		task.indexes, _ = parseIndexCopy(t.Indexes)
+		task.staging = t.Staging
+		task.transaction = t.Transaction
+		task.sync = t.Sync
+		task.watermark = t.Watermark
+		task.transform = t.Transform
+		m.collectionChoices.copyTasks = append(m.collectionChoices.copyTasks, task)
+
+		m.databaseChoices.sourceCollections = removeItem(m.databaseChoices.sourceCollections, si)
+	}
+	m.plan = nil
+
+	if len(missing) > 0 {
+		return fmt.Errorf("plan collections not found: %v", missing)
+	}
+
+	return nil
+}
+
+// Index of the collection with the given name, or -1 if there is none
+func collectionIndex(collections []collection, name string) int {
+	for i := range collections {
+		if collections[i].name == name {
+			return i
+		}
+	}
+
+	return -1
+}
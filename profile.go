@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/evertras/bubble-table/table"
+)
+
+const (
+	sourceProfilesColumnName = "Source Profiles"
+	targetProfilesColumnName = "Target Profiles"
+	profileHostColumnName    = "Host"
+)
+
+// Model for view where user chooses the source and target server profiles
+type profileChoicesViewModel struct {
+	profiles            []profile   // Profiles in config
+	sourceProfileChoice string      // Profile chosen as the source
+	targetProfileChoice string      // Profile chosen as the target
+	profilesChosen      bool        // Has user made profile selections
+	sourceTable         table.Model // Table that displays profiles to choose the source from
+	sourceTableFiltered bool
+	targetTable         table.Model // Table that displays profiles to choose the target from
+	targetTableFiltered bool
+}
+
+// Model for choosing profiles, already chosen when both were given as flags or config has none
+func newProfileChoices(c config, source string, target string) profileChoicesViewModel {
+	var pcvm profileChoicesViewModel
+	pcvm.profiles = c.profiles()
+	pcvm.profilesChosen = len(c.Profiles) == 0 || (source != "" && target != "")
+	if pcvm.profilesChosen {
+		pcvm.sourceProfileChoice = source
+		pcvm.targetProfileChoice = target
+	}
+	pcvm.sourceTable = buildTable([]table.Column{
+		table.NewColumn(sourceProfilesColumnName, sourceProfilesColumnName, 20).WithFiltered(true),
+		table.NewColumn(profileHostColumnName, profileHostColumnName, 25),
+	}).
+		WithPageSize(5).
+		Focused(true)
+	pcvm.targetTable = buildTable([]table.Column{
+		table.NewColumn(targetProfilesColumnName, targetProfilesColumnName, 20).WithFiltered(true),
+		table.NewColumn(profileHostColumnName, profileHostColumnName, 25),
+	}).
+		WithPageSize(5).
+		Focused(false)
+
+	return pcvm
+}
+
+// Update loop for the view where you're choosing the source and target profiles.
+func updateProfileChoices(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	var (
+		cmd  tea.Cmd
+		cmds []tea.Cmd
+	)
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keyBindings.keys.FilterQuit):
+			if m.profileChoices.sourceTable.GetFocused() {
+				m.profileChoices.sourceTableFiltered = false
+			} else if m.profileChoices.targetTable.GetFocused() {
+				m.profileChoices.targetTableFiltered = false
+			}
+		case key.Matches(msg, m.keyBindings.keys.FilterStart):
+			// Set as as filtered so we can update the UI to give a clue before user input
+			if m.profileChoices.sourceTable.GetFocused() {
+				m.profileChoices.sourceTableFiltered = true
+			} else if m.profileChoices.targetTable.GetFocused() {
+				m.profileChoices.targetTableFiltered = true
+			}
+		case key.Matches(msg, m.keyBindings.keys.Select):
+			if m.profileChoices.sourceTable.GetFocused() && m.profileChoices.sourceTable.TotalRows() > 0 {
+				row := m.profileChoices.sourceTable.HighlightedRow()
+				m.profileChoices.sourceProfileChoice = row.Data[sourceProfilesColumnName].(string)
+
+				m.profileChoices.sourceTable = m.profileChoices.sourceTable.Focused(false)
+				m.profileChoices.targetTable = m.profileChoices.targetTable.Focused(true)
+			} else if m.profileChoices.targetTable.GetFocused() && m.profileChoices.targetTable.TotalRows() > 0 {
+				row := m.profileChoices.targetTable.HighlightedRow()
+				m.profileChoices.targetProfileChoice = row.Data[targetProfilesColumnName].(string)
+				m.profileChoices.profilesChosen = true
+
+				// Connect to the chosen servers and load their databases
+				source := m.profileChoices.profile(m.profileChoices.sourceProfileChoice)
+				target := m.profileChoices.profile(m.profileChoices.targetProfileChoice)
+				var err error
+				m.storage, err = m.storage.withServers(target.Server, source.Server).connect()
+				if err != nil {
+					return m, func() tea.Msg { return errMsg{err, "connecting to servers"} }
+				}
+
+				return m, m.getSourceDatabases
+			}
+		}
+	}
+
+	m.profileChoices.targetTable, cmd = m.profileChoices.targetTable.Update(msg)
+	cmds = append(cmds, cmd)
+
+	m.profileChoices.sourceTable, cmd = m.profileChoices.sourceTable.Update(msg)
+	cmds = append(cmds, cmd)
+
+	var stfilterText string
+	if len(m.profileChoices.sourceTable.GetCurrentFilter()) > 0 || m.profileChoices.sourceTableFiltered {
+		stfilterText = fmt.Sprintf("\nFilter: %s", m.profileChoices.sourceTable.GetCurrentFilter())
+	}
+
+	// Add Custom footers
+	m.profileChoices.sourceTable = m.profileChoices.sourceTable.WithStaticFooter(
+		fmt.Sprintf("Page %d/%d \nProfiles %d \n%s",
+			m.profileChoices.sourceTable.CurrentPage(),
+			m.profileChoices.sourceTable.MaxPages(),
+			m.profileChoices.sourceTable.TotalRows(),
+			stfilterText),
+	)
+
+	var ttfilterText string
+	if len(m.profileChoices.targetTable.GetCurrentFilter()) > 0 || m.profileChoices.targetTableFiltered {
+		ttfilterText = fmt.Sprintf("\nFilter: %s", m.profileChoices.targetTable.GetCurrentFilter())
+	}
+
+	m.profileChoices.targetTable = m.profileChoices.targetTable.WithStaticFooter(
+		fmt.Sprintf("Page %d/%d \nProfiles %d \n%s",
+			m.profileChoices.targetTable.CurrentPage(),
+			m.profileChoices.targetTable.MaxPages(),
+			m.profileChoices.targetTable.TotalRows(),
+			ttfilterText),
+	)
+
+	return m, tea.Batch(cmds...)
+}
+
+// The first view where user is choosing the source and target profiles
+func profileChoicesView(m model) string {
+	tpl := green.Render(banner) + "\n"
+	tpl += "Choose the source and target servers"
+	tpl += "\n\n%s"
+	tpl += m.databaseChoicesHelp()
+
+	pad := lipgloss.NewStyle().Padding(1)
+	tables := []string{
+		lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.profileChoices.sourceTable.View())),
+		lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.profileChoices.targetTable.View())),
+	}
+
+	return fmt.Sprintf(tpl, lipgloss.JoinHorizontal(lipgloss.Top, tables...))
+}
+
+// Build rows for source and target profile tables
+func (m *model) buildProfileTableRows() {
+	sourceTableData := []table.RowData{}
+	targetTableData := []table.RowData{}
+
+	for _, p := range m.profileChoices.profiles {
+		sourceTableData = append(sourceTableData, map[string]interface{}{
+			sourceProfilesColumnName: p.Name,
+			profileHostColumnName:    p.host()})
+		// Warn before a protected server is chosen as the target
+		host := p.host()
+		if p.Protected {
+			host = red.Render(host + " (protected)")
+		}
+		targetTableData = append(targetTableData, map[string]interface{}{
+			targetProfilesColumnName: p.Name,
+			profileHostColumnName:    host})
+	}
+
+	m.profileChoices.sourceTable = m.profileChoices.sourceTable.WithRows(buildRows(sourceTableData))
+	m.profileChoices.targetTable = m.profileChoices.targetTable.WithRows(buildRows(targetTableData))
+}
+
+// Find chosen profile by name
+func (vm profileChoicesViewModel) profile(name string) profile {
+	for _, p := range vm.profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return profile{}
+}
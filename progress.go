@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const minProgressBarWidth = 10 // Narrowest bar drawn next to the progress text
+
+// Number of documents a copy has written to the target out of the number it will write
+type copyProgress struct {
+	copied int64
+	total  int64
+
+	syncing bool          // Initial copy has finished and source changes are being applied
+	changes int64         // Source changes applied to the target since the sync started tailing
+	lag     time.Duration // How far the target is behind the source, zero when caught up
+}
+
+// Fraction of documents copied, between 0 and 1
+func (p copyProgress) percent() float64 {
+	if p.total <= 0 {
+		return 0
+	}
+
+	return math.Min(float64(p.copied)/float64(p.total), 1)
+}
+
+// Documents copied per second over the elapsed time
+func (p copyProgress) rate(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(p.copied) / elapsed.Seconds()
+}
+
+// Estimated time until the copy finishes at the current rate, false if no documents have been copied yet
+func (p copyProgress) eta(elapsed time.Duration) (time.Duration, bool) {
+	rate := p.rate(elapsed)
+	if rate == 0 {
+		return 0, false
+	}
+
+	remaining := math.Max(float64(p.total-p.copied), 0)
+	return time.Duration(remaining / rate * float64(time.Second)).Round(time.Second), true
+}
+
+// Render progress as a bar followed by documents copied, rate and ETA, fitting the given width
+func (p copyProgress) view(elapsed time.Duration, width int) string {
+	text := fmt.Sprintf("%d/%d %.0f/s", p.copied, p.total, p.rate(elapsed))
+	if p.syncing {
+		text = fmt.Sprintf("%d changes, lag %s", p.changes, p.lag.Round(time.Second))
+	} else if eta, ok := p.eta(elapsed); ok {
+		text += fmt.Sprintf(" ETA %s", eta)
+	}
+
+	barWidth := width - len(text) - 1
+	if barWidth < minProgressBarWidth {
+		barWidth = minProgressBarWidth
+	}
+
+	percent := p.percent()
+	if p.syncing {
+		percent = 1
+	}
+
+	return progressBar(percent, barWidth) + " " + text
+}
+
+// Render a bar of the given width filled in proportion to percent
+func progressBar(percent float64, width int) string {
+	filled := int(math.Round(percent * float64(width)))
+	filled = max(0, min(filled, width))
+
+	return green.Render(strings.Repeat("█", filled)) + subtleStyle.Render(strings.Repeat("░", width-filled))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyProgressPercent(t *testing.T) {
+	if p := (copyProgress{copied: 25, total: 100}).percent(); p != 0.25 {
+		t.Errorf("expected 0.25, got %f", p)
+	}
+	if p := (copyProgress{copied: 5}).percent(); p != 0 {
+		t.Errorf("expected 0 without a total, got %f", p)
+	}
+	if p := (copyProgress{copied: 150, total: 100}).percent(); p != 1 {
+		t.Errorf("expected percent to stop at 1, got %f", p)
+	}
+}
+
+func TestCopyProgressRateAndETA(t *testing.T) {
+	p := copyProgress{copied: 100, total: 400}
+	if rate := p.rate(10 * time.Second); rate != 10 {
+		t.Errorf("expected 10 documents per second, got %f", rate)
+	}
+	if eta, ok := p.eta(10 * time.Second); !ok || eta != 30*time.Second {
+		t.Errorf("expected ETA of 30s, got %s", eta)
+	}
+	if _, ok := (copyProgress{total: 400}).eta(10 * time.Second); ok {
+		t.Error("expected no ETA before any documents are copied")
+	}
+}
+
+func TestCopyProgressView(t *testing.T) {
+	view := copyProgress{copied: 100, total: 400}.view(10*time.Second, progressBarWidth)
+	if !strings.Contains(view, "100/400 10/s ETA 30s") {
+		t.Errorf("expected counts, rate and ETA in %q", view)
+	}
+
+	view = copyProgress{copied: 400, total: 400, syncing: true, changes: 12, lag: 2400 * time.Millisecond}.view(time.Minute, progressBarWidth)
+	if !strings.Contains(view, "12 changes, lag 2s") {
+		t.Errorf("expected changes and lag in %q", view)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	bar := progressBar(0.5, 10)
+	if strings.Count(bar, "█") != 5 || strings.Count(bar, "░") != 5 {
+		t.Errorf("expected half filled bar, got %q", bar)
+	}
+}
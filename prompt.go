@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// What the value entered in a prompt is used for
+type promptKind int
+
+const (
+	promptNone         promptKind = iota
+	promptWriteKey                // Key field matching documents for a copy task's write mode
+	promptSavePlan                // File the copy tasks are saved to as a plan
+	promptFilter                  // Query filter choosing which documents a copy task copies
+	promptNewTarget               // Name of a target collection created for the chosen source collection
+	promptCopyDatabase            // Patterns of the collections copied by a whole-database copy
+	promptAutoMap                 // Rename rule pairing source collections with target collections
+	promptConfirmCopy             // Target database name typed to confirm a destructive or protected copy
+	promptWatermark               // Increasing field an incremental copy task copies newer documents of
+)
+
+// Single line text input shown under the current view
+type prompt struct {
+	kind   promptKind
+	taskId int    // Copy task the prompt edits
+	title  string // Text shown above the input
+	input  textinput.Model
+}
+
+// Open a prompt of the given kind with an initial value
+func newPrompt(kind promptKind, taskId int, title string, value string) (prompt, tea.Cmd) {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.SetValue(value)
+	input.CursorEnd()
+	cmd := input.Focus()
+
+	return prompt{kind: kind, taskId: taskId, title: title, input: input}, cmd
+}
+
+// Check if the prompt is open
+func (p prompt) active() bool {
+	return p.kind != promptNone
+}
+
+func (p prompt) View() string {
+	return fmt.Sprintf("%s\n%s\n%s", p.title, p.input.View(), subtleStyle.Render("enter: save • esc: cancel"))
+}
+
+// Update loop while a prompt is open. Enter saves the value and esc closes the prompt without saving.
+func updatePrompt(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, m.keyBindings.keys.Enter):
+			p := m.prompt
+			m.prompt = prompt{}
+			return m, m.submitPrompt(p, strings.TrimSpace(p.input.Value()))
+		case key.Matches(msg, m.keyBindings.keys.FilterQuit):
+			m.prompt = prompt{}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.prompt.input, cmd = m.prompt.input.Update(msg)
+	return m, cmd
+}
+
+// Use the value entered in a prompt
+func (m *model) submitPrompt(p prompt, value string) tea.Cmd {
+	switch p.kind {
+	case promptWriteKey:
+		if i := m.copyTaskIndex(p.taskId); i >= 0 {
+			if value == "" {
+				value = defaultWriteKey
+			}
+			m.collectionChoices.copyTasks[i].key = value
+			m.collectionChoices.copyTasks[i].dryRunReport = nil
+		}
+	case promptFilter:
+		if i := m.copyTaskIndex(p.taskId); i >= 0 {
+			filter, err := normalizeExtJSON(value)
+			if err != nil {
+				m.collectionChoices.message = red.Render(fmt.Sprintf("Invalid filter: %s", err))
+				break
+			}
+			m.collectionChoices.copyTasks[i].query.filter = filter
+			m.collectionChoices.copyTasks[i].dryRunReport = nil
+			m.collectionChoices.message = ""
+		}
+	case promptWatermark:
+		if i := m.copyTaskIndex(p.taskId); i >= 0 {
+			task := &m.collectionChoices.copyTasks[i]
+			task.watermark = value
+
+			// Incremental copies add to the target, so they upsert unless already keyed
+			if value != "" {
+				if !task.mode.keyed() {
+					task.mode = modeUpsert
+				}
+				task.staging = false
+				task.sync = false
+			}
+			task.dryRunReport = nil
+		}
+	case promptNewTarget:
+		cmd, err := m.addNewTargetCopyTask(value)
+		if err != nil {
+			m.collectionChoices.message = red.Render(fmt.Sprintf("Invalid collection: %s", err))
+		} else {
+			m.collectionChoices.message = ""
+		}
+		m.buildCollectionMapRows()
+		return cmd
+	case promptCopyDatabase:
+		patterns, err := parseCollectionPatterns(value)
+		if err != nil {
+			m.databaseChoices.message = red.Render(fmt.Sprintf("Invalid patterns: %s", err))
+			return nil
+		}
+		m.databaseChoices.message = ""
+		m.databaseChoices.wholeDatabase = &patterns
+		m.databaseChoices.databasesChosen = true
+		return m.getCollections
+	case promptAutoMap:
+		rule, err := parseRenameRule(value)
+		if err != nil {
+			m.collectionChoices.message = red.Render(fmt.Sprintf("Invalid rename rule: %s", err))
+			break
+		}
+
+		mapped := m.autoMapCollections(rule)
+		m.buildCollectionTableRows()
+		m.buildCollectionMapRows()
+		if mapped == 0 {
+			m.collectionChoices.message = red.Render("No source collections match a target collection")
+			return nil
+		}
+		m.collectionChoices.message = green.Render(fmt.Sprintf("Mapped %d collections", mapped))
+
+		// Nothing left to pair by hand so switch to copy task view
+		if len(m.databaseChoices.sourceCollections) == 0 || len(m.databaseChoices.targetCollections) == 0 {
+			m.collectionChoices.sourceTable = m.collectionChoices.sourceTable.Focused(false)
+			m.collectionChoices.targetTable = m.collectionChoices.targetTable.Focused(false)
+			m.collectionChoices.copyTaskTable = m.collectionChoices.copyTaskTable.Focused(true)
+			m.collectionChoices.altscreen = true
+			return tea.EnterAltScreen
+		}
+		return nil
+	case promptConfirmCopy:
+		if value != m.databaseChoices.targetDatabaseChoice {
+			m.collectionChoices.message = red.Render(fmt.Sprintf("Typed name does not match %s, copy not started", m.databaseChoices.targetDatabaseChoice))
+			return nil
+		}
+		m.collectionChoices.message = ""
+		return m.startCopy()
+	case promptSavePlan:
+		if value == "" {
+			return nil
+		}
+		p := m.exportPlan()
+		if err := savePlan(value, p); err != nil {
+			m.collectionChoices.message = red.Render(fmt.Sprintf("Could not save plan: %s", err))
+		} else if servers := p.serversWithoutProfile(); len(servers) > 0 {
+			m.collectionChoices.message = red.Render(fmt.Sprintf("Plan saved to %s without a profile for the %s, credentials must be given when it runs", value, strings.Join(servers, " and ")))
+		} else {
+			m.collectionChoices.message = green.Render(fmt.Sprintf("Plan saved to %s", value))
+		}
+	}
+
+	m.buildCollectionMapRows()
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/square/exit"
+)
+
+// Servers and databases that copies only write to once the user has confirmed by typing the database name
+type protection struct {
+	hosts     []string // Hosts of protected profile servers
+	databases []string // Glob patterns of protected database names
+}
+
+// Protected targets set in config
+func (c config) protection() protection {
+	var p protection
+	for _, profile := range c.Profiles {
+		if profile.Protected {
+			p.hosts = append(p.hosts, profile.host())
+		}
+	}
+	p.databases = c.ProtectedDatabases
+
+	return p
+}
+
+// Check if a database on a server is protected. Servers are compared by host, so a protected
+// server stays protected when it is reached with other credentials or options.
+func (p protection) protects(server string, database string) bool {
+	host := serverHost(server)
+	for _, h := range p.hosts {
+		if h == host {
+			return true
+		}
+	}
+	for _, pattern := range p.databases {
+		if ok, _ := path.Match(pattern, database); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Confirmation the pending copy tasks need before they start, empty when they can start straight away.
+// Copies to protected targets and copies that delete target documents have to be confirmed.
+func (m model) copyConfirmation() string {
+	var deletes int64
+	collections := 0
+	for _, t := range m.collectionChoices.copyTasks {
+		if t.state == taskPending && t.mode.destructive() && !t.resume && !t.target.create && t.target.count > 0 {
+			deletes += t.target.count
+			collections++
+		}
+	}
+
+	database := m.databaseChoices.targetDatabaseChoice
+	protected := m.protection.protects(m.storage.targetURI, database)
+	if deletes == 0 && !protected {
+		return ""
+	}
+
+	target := fmt.Sprintf("%s on %s", database, serverHost(m.storage.targetURI))
+	if protected {
+		target += " (protected)"
+	}
+	if deletes == 0 {
+		return fmt.Sprintf("Copying writes to %s. Type the database name to start copying", target)
+	}
+
+	return fmt.Sprintf("Copying deletes %d documents from %d collections in %s. Type the database name to start copying",
+		deletes, collections, target)
+}
+
+// Check a headless copy to a protected target was confirmed by naming the target database
+func confirmTarget(command string, cfg config, s storage, database string, confirm string) error {
+	if confirm == database || !cfg.protection().protects(s.targetURI, database) {
+		return nil
+	}
+
+	return exit.Wrap(fmt.Errorf("%s: target database %s on %s is protected, pass -confirm %s to copy to it",
+		command, database, serverHost(s.targetURI), database), exit.Forbidden)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Subset of source documents to copy. Filter, sort and projection are extended JSON documents.
+type copyQuery struct {
+	filter     string
+	sort       string
+	projection string
+	limit      int64
+}
+
+// Parsed copy query ready to pass to the driver
+type parsedQuery struct {
+	filter     bson.D
+	sort       bson.D
+	projection bson.D
+	limit      int64
+}
+
+// Check if the query copies every document of the collection
+func (q copyQuery) empty() bool {
+	return q.filter == "" && q.sort == "" && q.projection == "" && q.limit == 0
+}
+
+// Parse the extended JSON parts of the query
+func (q copyQuery) parse() (parsedQuery, error) {
+	var p parsedQuery
+	var err error
+
+	if p.filter, err = parseExtJSON(q.filter); err != nil {
+		return p, fmt.Errorf("invalid filter: %w", err)
+	}
+	if p.sort, err = parseExtJSON(q.sort); err != nil {
+		return p, fmt.Errorf("invalid sort: %w", err)
+	}
+	if p.projection, err = parseExtJSON(q.projection); err != nil {
+		return p, fmt.Errorf("invalid projection: %w", err)
+	}
+	if q.limit < 0 {
+		return p, fmt.Errorf("invalid limit %d", q.limit)
+	}
+	p.limit = q.limit
+
+	return p, nil
+}
+
+// Check if documents have to be read in a single pass, so the collection can't be split between workers
+func (p parsedQuery) ordered() bool {
+	return len(p.sort) > 0 || p.limit > 0
+}
+
+// Check if the projection leaves out the _id, so documents read have no position to checkpoint
+func (p parsedQuery) excludesID() bool {
+	for _, e := range p.projection {
+		if e.Key != "_id" {
+			continue
+		}
+		switch v := e.Value.(type) {
+		case bool:
+			return !v
+		case int32:
+			return v == 0
+		case int64:
+			return v == 0
+		case float64:
+			return v == 0
+		}
+	}
+
+	return false
+}
+
+// Options for counting the documents the query copies
+func (p parsedQuery) countOptions() *options.CountOptions {
+	opts := options.Count()
+	if p.limit > 0 {
+		opts.SetLimit(p.limit)
+	}
+
+	return opts
+}
+
+// Options for finding the documents the query copies
+func (p parsedQuery) findOptions() *options.FindOptions {
+	opts := options.Find()
+	if len(p.sort) > 0 {
+		opts.SetSort(p.sort)
+	}
+	if len(p.projection) > 0 {
+		opts.SetProjection(p.projection)
+	}
+	if p.limit > 0 {
+		opts.SetLimit(p.limit)
+	}
+
+	return opts
+}
+
+// Combine the query filter with another filter, such as a partition range
+func (p parsedQuery) and(filter bson.D) bson.D {
+	if len(p.filter) == 0 {
+		return filter
+	} else if len(filter) == 0 {
+		return p.filter
+	}
+
+	return bson.D{{Key: "$and", Value: bson.A{p.filter, filter}}}
+}
+
+// Short description of the query for the copy task table
+func (q copyQuery) String() string {
+	var parts []string
+	if q.filter != "" {
+		parts = append(parts, q.filter)
+	}
+	if q.sort != "" {
+		parts = append(parts, "sort "+q.sort)
+	}
+	if q.projection != "" {
+		parts = append(parts, "project "+q.projection)
+	}
+	if q.limit > 0 {
+		parts = append(parts, fmt.Sprintf("limit %d", q.limit))
+	}
+	if len(parts) == 0 {
+		return "all documents"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Parse extended JSON document, an empty string is an empty document
+func parseExtJSON(s string) (bson.D, error) {
+	d := bson.D{}
+	if strings.TrimSpace(s) == "" {
+		return d, nil
+	}
+
+	err := bson.UnmarshalExtJSON([]byte(s), false, &d)
+	return d, err
+}
+
+// Parse extended JSON document and write it back in relaxed form without extra spacing
+func normalizeExtJSON(s string) (string, error) {
+	d, err := parseExtJSON(s)
+	if err != nil || len(d) == 0 {
+		return "", err
+	}
+
+	out, err := bson.MarshalExtJSON(d, false, false)
+	return string(out), err
+}
+
+// Extended JSON document embedded in a JSON file such as a plan, empty when it is not set
+func rawExtJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+
+	return json.RawMessage(s)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCopyQueryParse(t *testing.T) {
+	q := copyQuery{
+		filter:     `{"tenant": "acme", "createdAt": {"$gte": {"$date": "2024-01-01T00:00:00Z"}}}`,
+		sort:       `{"createdAt": -1}`,
+		projection: `{"secret": 0}`,
+		limit:      50,
+	}
+
+	p, err := q.parse()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(p.filter) != 2 || p.filter[0].Key != "tenant" {
+		t.Errorf("unexpected filter %v", p.filter)
+	}
+	if !p.ordered() {
+		t.Error("expected sorted query to be ordered")
+	}
+	if opts := p.findOptions(); *opts.Limit != 50 || opts.Sort == nil || opts.Projection == nil {
+		t.Errorf("unexpected find options %+v", opts)
+	}
+	if opts := p.countOptions(); *opts.Limit != 50 {
+		t.Errorf("expected count limit 50, got %v", *opts.Limit)
+	}
+}
+
+func TestCopyQueryParse_Invalid(t *testing.T) {
+	for _, q := range []copyQuery{
+		{filter: `{tenant: acme}`},
+		{sort: `[1]`},
+		{projection: `{`},
+		{limit: -1},
+	} {
+		if _, err := q.parse(); err == nil {
+			t.Errorf("expected error for %+v", q)
+		}
+	}
+}
+
+func TestCopyQueryParse_Empty(t *testing.T) {
+	p, err := copyQuery{}.parse()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(p.filter) != 0 || p.ordered() {
+		t.Errorf("expected empty unordered query, got %+v", p)
+	}
+}
+
+func TestParsedQueryAnd(t *testing.T) {
+	partition := bson.D{{Key: "_id", Value: bson.D{{Key: "$lt", Value: 10}}}}
+
+	if got := (parsedQuery{}).and(partition); len(got) != 1 || got[0].Key != "_id" {
+		t.Errorf("expected partition filter on its own, got %v", got)
+	}
+
+	p := parsedQuery{filter: bson.D{{Key: "tenant", Value: "acme"}}}
+	if got := p.and(bson.D{}); len(got) != 1 || got[0].Key != "tenant" {
+		t.Errorf("expected query filter on its own, got %v", got)
+	}
+	if got := p.and(partition); len(got) != 1 || got[0].Key != "$and" {
+		t.Errorf("expected filters combined with $and, got %v", got)
+	}
+}
+
+func TestParsedQueryExcludesID(t *testing.T) {
+	for projection, want := range map[string]bool{
+		`{"_id":0}`:              true,
+		`{"_id":false,"name":1}`: true,
+		`{"_id":1,"name":1}`:     false,
+		`{"name":0}`:             false,
+		``:                       false,
+	} {
+		q, err := copyQuery{projection: projection}.parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.excludesID(); got != want {
+			t.Errorf("%s: expected %v, got %v", projection, want, got)
+		}
+	}
+}
+
+func TestNormalizeExtJSON(t *testing.T) {
+	got, err := normalizeExtJSON(`{ "tenant" :  "acme" }`)
+	if err != nil || got != `{"tenant":"acme"}` {
+		t.Errorf("unexpected normalized filter %q %v", got, err)
+	}
+
+	if got, err := normalizeExtJSON("  "); err != nil || got != "" {
+		t.Errorf("expected empty filter, got %q %v", got, err)
+	}
+}
+
+func TestCopyQueryString(t *testing.T) {
+	if got := (copyQuery{}).String(); got != "all documents" {
+		t.Errorf("unexpected description %q", got)
+	}
+	if got := (copyQuery{filter: `{"a":1}`, limit: 5}).String(); got != `{"a":1} limit 5` {
+		t.Errorf("unexpected description %q", got)
+	}
+}
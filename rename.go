@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// How a source collection name is turned into the name of the target collection it is mapped to
+type renameRule struct {
+	prefix      string         // Added in front of the source name
+	suffix      string         // Added after the source name
+	pattern     *regexp.Regexp // Replaced in the source name when set
+	replacement string
+}
+
+// Parse a rename rule, one of "prefix=<text>", "suffix=<text>" or "s/<regexp>/<replacement>/"
+// where the replacement may refer to groups as $1. An empty rule keeps the source name.
+func parseRenameRule(s string) (renameRule, error) {
+	var r renameRule
+	s = strings.TrimSpace(s)
+
+	switch {
+	case s == "":
+		return r, nil
+	case strings.HasPrefix(s, "prefix="):
+		r.prefix = strings.TrimPrefix(s, "prefix=")
+	case strings.HasPrefix(s, "suffix="):
+		r.suffix = strings.TrimPrefix(s, "suffix=")
+	case strings.HasPrefix(s, "s/"):
+		parts := strings.Split(s, "/")
+		if len(parts) != 4 || parts[3] != "" || parts[1] == "" {
+			return r, errors.New("regexp rule must look like s/<regexp>/<replacement>/")
+		}
+		pattern, err := regexp.Compile(parts[1])
+		if err != nil {
+			return r, fmt.Errorf("invalid regexp: %w", err)
+		}
+		r.pattern = pattern
+		r.replacement = parts[2]
+	default:
+		return r, fmt.Errorf("unknown rename rule %q, use prefix=, suffix= or s/<regexp>/<replacement>/", s)
+	}
+
+	return r, nil
+}
+
+// Name of the target collection for a source collection
+func (r renameRule) apply(name string) string {
+	if r.pattern != nil {
+		name = r.pattern.ReplaceAllString(name, r.replacement)
+	}
+
+	return r.prefix + name + r.suffix
+}
+
+// Pair every source collection with the target collection named by the rename rule, adding a copy task
+// for each pair and taking both collections out of the tables. Collections without a match are left in
+// the tables to be paired by hand. Returns the number of copy tasks added.
+func (m *model) autoMapCollections(rule renameRule) int {
+	var sources []collection
+	mapped := 0
+
+	for _, source := range m.databaseChoices.sourceCollections {
+		ti := collectionIndex(m.databaseChoices.targetCollections, rule.apply(source.name))
+		if ti < 0 {
+			sources = append(sources, source)
+			continue
+		}
+
+		task := newCollectionCopyTask(source, m.databaseChoices.targetCollections[ti])
+		m.collectionChoices.copyTasks = append(m.collectionChoices.copyTasks, task)
+		m.databaseChoices.targetCollections = removeItem(m.databaseChoices.targetCollections, ti)
+		mapped++
+	}
+	m.databaseChoices.sourceCollections = sources
+
+	return mapped
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseRenameRule(t *testing.T) {
+	cases := map[string]string{
+		"":                     "orders",
+		"prefix=archive_":      "archive_orders",
+		"suffix=_v2":           "orders_v2",
+		"s/^(.*)s$/${1}_list/": "order_list",
+	}
+	for rule, want := range cases {
+		r, err := parseRenameRule(rule)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", rule, err)
+			continue
+		}
+		if got := r.apply("orders"); got != want {
+			t.Errorf("%q renamed orders to %s, want %s", rule, got, want)
+		}
+	}
+
+	for _, rule := range []string{"rename=x", "s/(/x/", "s//x/", "s/a/b"} {
+		if _, err := parseRenameRule(rule); err == nil {
+			t.Errorf("expected %q to be invalid", rule)
+		}
+	}
+}
+
+func TestAutoMapCollections(t *testing.T) {
+	m := model{}
+	m.databaseChoices.sourceCollections = []collection{{name: "orders", count: 3}, {name: "users"}, {name: "events"}}
+	m.databaseChoices.targetCollections = []collection{{name: "archive_users"}, {name: "archive_orders"}, {name: "audit"}}
+
+	rule, _ := parseRenameRule("prefix=archive_")
+	if mapped := m.autoMapCollections(rule); mapped != 2 {
+		t.Fatalf("expected 2 collections to be mapped, got %d", mapped)
+	}
+
+	tasks := m.collectionChoices.copyTasks
+	if tasks[0].source.name != "orders" || tasks[0].target.name != "archive_orders" || tasks[0].source.count != 3 {
+		t.Errorf("unexpected first task %v -> %v", tasks[0].source, tasks[0].target)
+	}
+	if tasks[1].source.name != "users" || tasks[1].target.name != "archive_users" {
+		t.Errorf("unexpected second task %v -> %v", tasks[1].source, tasks[1].target)
+	}
+	if len(m.databaseChoices.sourceCollections) != 1 || m.databaseChoices.sourceCollections[0].name != "events" {
+		t.Errorf("expected unmatched source to be left, got %v", m.databaseChoices.sourceCollections)
+	}
+	if len(m.databaseChoices.targetCollections) != 1 || m.databaseChoices.targetCollections[0].name != "audit" {
+		t.Errorf("expected unmatched target to be left, got %v", m.databaseChoices.targetCollections)
+	}
+
+	if mapped := m.autoMapCollections(renameRule{}); mapped != 0 || len(m.collectionChoices.copyTasks) != 2 {
+		t.Errorf("expected no more collections to be mapped, got %d", mapped)
+	}
+}
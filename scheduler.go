@@ -0,0 +1,31 @@
+package main
+
+import "context"
+
+const defaultMaxConcurrentCopies = 4 // Collection copies running at once when not set in config
+
+// Limits how many collection copies run at the same time
+type scheduler struct {
+	slots chan struct{}
+}
+
+// Initialize new scheduler allowing limit copies at once, falling back to the default when not set
+func newScheduler(limit int) *scheduler {
+	if limit <= 0 {
+		limit = defaultMaxConcurrentCopies
+	}
+
+	return &scheduler{slots: make(chan struct{}, limit)}
+}
+
+// Run fn once a slot is free, blocking until then or until the context is cancelled
+func (s *scheduler) run(ctx context.Context, fn func() error) error {
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.slots }()
+
+	return fn()
+}
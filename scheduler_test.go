@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewScheduler_Default(t *testing.T) {
+	s := newScheduler(0)
+	if cap(s.slots) != defaultMaxConcurrentCopies {
+		t.Errorf("expected %d slots, got %d", defaultMaxConcurrentCopies, cap(s.slots))
+	}
+}
+
+func TestScheduler_LimitsConcurrency(t *testing.T) {
+	s := newScheduler(2)
+	var running, peak int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run(context.Background(), func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent runs, got %d", peak)
+	}
+}
+
+func TestScheduler_ReturnsError(t *testing.T) {
+	s := newScheduler(1)
+	want := errors.New("copy failed")
+	if err := s.run(context.Background(), func() error { return want }); err != want {
+		t.Errorf("expected %v, got %v", want, err)
+	}
+}
+
+func TestScheduler_Cancelled(t *testing.T) {
+	s := newScheduler(1)
+	s.slots <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	if err := s.run(ctx, func() error { ran = true; return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancelled error, got %v", err)
+	}
+	if ran {
+		t.Error("expected cancelled run not to wait for a slot")
+	}
+}
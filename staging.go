@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Name of the collection a staged copy loads before it replaces the target
+func stagingName(target string) string {
+	return target + ".staging"
+}
+
+// Create the empty staging collection a staged copy writes to, with the options and indexes of the target,
+// or the source options when the target is created. Resumed copies continue with the staging collection
+// left by the copy that stopped. A source view is created on the target directly, reported by the returned flag.
+func (s storage) prepareStaging(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection, spec copySpec) (*mongo.Collection, bool, error) {
+	if spec.mode != modeReplace {
+		return nil, false, errors.New("staged copies only work in replace mode")
+	}
+	staging := tc.Database().Collection(stagingName(tc.Name()))
+
+	if spec.resume {
+		existing, err := collectionSpecification(ctx, staging)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read staging collection: %w", err)
+		} else if existing == nil {
+			return nil, false, errors.New("no staging collection to resume copy into")
+		}
+		return staging, false, nil
+	}
+
+	target, err := collectionSpecification(ctx, tc)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read target collection options: %w", err)
+	}
+	options := target
+	if spec.create {
+		if options, err = collectionSpecification(ctx, sc); err != nil {
+			return nil, false, fmt.Errorf("failed to read source collection options: %w", err)
+		} else if options == nil {
+			return nil, false, fmt.Errorf("source collection %s does not exist", sc.Name())
+		}
+	}
+
+	// Views have no documents to stage
+	if spec.create && options.Type == "view" {
+		view, err := s.createCollection(ctx, sc, tc)
+		return nil, view, err
+	} else if target != nil && target.Type == "view" {
+		return nil, false, fmt.Errorf("target %s is a view", tc.Name())
+	}
+
+	// A staging collection left by an earlier copy that failed is started again
+	if err := staging.Drop(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to drop staging collection: %w", err)
+	}
+	var raw bson.Raw
+	if options != nil {
+		raw = options.Options
+	}
+	cmd, err := createCommand(staging.Name(), raw)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := staging.Database().RunCommand(ctx, cmd).Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to create staging collection: %w", err)
+	}
+
+	// Renaming over the target drops its indexes, so they are built on the staging collection too
+	if target != nil {
+		if _, err := s.copyIndexes(ctx, tc, staging); err != nil {
+			return nil, false, fmt.Errorf("failed to copy target indexes to staging collection: %w", err)
+		}
+	}
+
+	return staging, false, nil
+}
+
+// Replace the target with the loaded staging collection in a single rename, so readers of the target
+// see either all of its old documents or all of the copied ones
+func (s storage) promoteStaging(ctx context.Context, tc *mongo.Collection, staging *mongo.Collection, spec copySpec) error {
+	if err := s.backupTarget(ctx, tc, spec); err != nil {
+		return err
+	}
+
+	cmd := bson.D{
+		{Key: "renameCollection", Value: staging.Database().Name() + "." + staging.Name()},
+		{Key: "to", Value: tc.Database().Name() + "." + tc.Name()},
+		{Key: "dropTarget", Value: true},
+	}
+	if err := tc.Database().Client().Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+		return fmt.Errorf("failed to replace target with staging collection: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStagingName(t *testing.T) {
+	if name := stagingName("orders"); name != "orders.staging" {
+		t.Errorf("unexpected staging collection name %s", name)
+	}
+}
+
+func TestPrepareStaging_ReplaceOnly(t *testing.T) {
+	s := newStorage("target", "source")
+	for _, mode := range []writeMode{modeAppend, modeUpsert, modeInsertMissing} {
+		_, _, err := s.prepareStaging(context.Background(), nil, nil, copySpec{mode: mode, staging: true})
+		if err == nil || err.Error() != "staged copies only work in replace mode" {
+			t.Errorf("%s: expected replace mode error, got %v", mode, err)
+		}
+	}
+}
+
+func TestCopyKey_Staged(t *testing.T) {
+	s := newStorage("mongodb://target:27017", "mongodb://source:27017")
+	spec := copySpec{sourceDatabase: "shop", sourceCollection: "orders", targetDatabase: "shop", targetCollection: "orders"}
+	direct := s.copyKey(spec)
+
+	spec.staging = true
+	if staged := s.copyKey(spec); staged == direct {
+		t.Error("expected staged copies to have their own checkpoints")
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const defaultStateFile = "mongo-move.state.json" // Local file progress is saved to when not set in config
+
+// Progress saved between runs
+type state struct {
+	Checkpoints map[string]checkpoint     `json:"checkpoints"`          // Copies that stopped part way, by copy key
+	Backups     []backupRecord            `json:"backups,omitempty"`    // Target collections saved before copies emptied them, oldest first
+	Syncs       map[string]syncCheckpoint `json:"syncs,omitempty"`      // Syncs that applied source changes to their target, by copy key
+	Watermarks  map[string]watermark      `json:"watermarks,omitempty"` // Highest values copied by incremental copies, by copy key
+}
+
+// How far a copy got through each of its _id ranges, so it can be resumed after it stopped part way
+type checkpoint struct {
+	Ranges []rangeCheckpoint `json:"ranges"`
+}
+
+// How far a copy got through one _id range. Values are extended JSON.
+type rangeCheckpoint struct {
+	Min  string `json:"min,omitempty"`  // Inclusive lower bound of the range
+	Max  string `json:"max,omitempty"`  // Exclusive upper bound of the range
+	Last string `json:"last,omitempty"` // _id of the last document written to the target
+	Done bool   `json:"done"`           // Has every document in the range been written
+}
+
+// State saved to a local file after every change, shared by all copies. A nil store saves nothing.
+type stateStore struct {
+	path  string
+	mu    sync.Mutex
+	state state
+}
+
+// Load state file, starting with empty state if the file does not exist yet
+func loadStateStore(path string) (*stateStore, error) {
+	if path == "" {
+		path = defaultStateFile
+	}
+
+	st, err := loadJSON[state](path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if st.Checkpoints == nil {
+		st.Checkpoints = map[string]checkpoint{}
+	}
+	if st.Syncs == nil {
+		st.Syncs = map[string]syncCheckpoint{}
+	}
+	if st.Watermarks == nil {
+		st.Watermarks = map[string]watermark{}
+	}
+
+	return &stateStore{path: path, state: st}, nil
+}
+
+// Write state to file, replacing the previous file only once the new one is complete.
+// Callers must hold the lock.
+func (ss *stateStore) save() error {
+	tmp := ss.path + ".tmp"
+	if err := saveJSON(tmp, ss.state); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, ss.path)
+}
+
+// Get checkpoint of a copy
+func (ss *stateStore) checkpoint(key string) (checkpoint, bool) {
+	if ss == nil {
+		return checkpoint{}, false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	cp, ok := ss.state.Checkpoints[key]
+	return cp, ok
+}
+
+// Save checkpoint of a copy
+func (ss *stateStore) setCheckpoint(key string, cp checkpoint) error {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.state.Checkpoints[key] = cp
+	return ss.save()
+}
+
+// Record the last _id written to the target for a range of a copy, marking the range done if it has finished
+func (ss *stateStore) advanceCheckpoint(key string, rangeIndex int, last bson.RawValue, done bool) error {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	cp, ok := ss.state.Checkpoints[key]
+	if !ok || rangeIndex >= len(cp.Ranges) {
+		return nil
+	}
+	if !isEmptyValue(last) {
+		encoded, err := encodeID(last)
+		if err != nil {
+			return err
+		}
+		cp.Ranges[rangeIndex].Last = encoded
+	}
+	cp.Ranges[rangeIndex].Done = done
+	ss.state.Checkpoints[key] = cp
+
+	return ss.save()
+}
+
+// Forget checkpoint of a copy that has finished
+func (ss *stateStore) removeCheckpoint(key string) error {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if _, ok := ss.state.Checkpoints[key]; !ok {
+		return nil
+	}
+	delete(ss.state.Checkpoints, key)
+	return ss.save()
+}
+
+// Get sync checkpoint of a copy
+func (ss *stateStore) syncCheckpoint(key string) (syncCheckpoint, bool) {
+	if ss == nil {
+		return syncCheckpoint{}, false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	sc, ok := ss.state.Syncs[key]
+	return sc, ok
+}
+
+// Save sync checkpoint of a copy
+func (ss *stateStore) setSyncCheckpoint(key string, sc syncCheckpoint) error {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	sc.Updated = time.Now().UTC()
+	ss.state.Syncs[key] = sc
+	return ss.save()
+}
+
+// Get watermark of an incremental copy
+func (ss *stateStore) watermark(key string) (watermark, bool) {
+	if ss == nil {
+		return watermark{}, false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	w, ok := ss.state.Watermarks[key]
+	return w, ok
+}
+
+// Save watermark of an incremental copy
+func (ss *stateStore) setWatermark(key string, w watermark) error {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	w.Updated = time.Now().UTC()
+	ss.state.Watermarks[key] = w
+	return ss.save()
+}
+
+// Check if a target collection has already been backed up in the run of a backup record
+func (ss *stateStore) hasBackup(r backupRecord) bool {
+	if ss == nil {
+		return false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for _, b := range ss.state.Backups {
+		if b.Run == r.Run && b.Host == r.Host && b.Database == r.Database && b.Collection == r.Collection {
+			return true
+		}
+	}
+	return false
+}
+
+// Save record of a backup that was taken
+func (ss *stateStore) addBackup(r backupRecord) error {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.state.Backups = append(ss.state.Backups, r)
+	return ss.save()
+}
+
+// Get records of the backups taken in the last run that took any
+func (ss *stateStore) lastRunBackups() []backupRecord {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var last string
+	for _, b := range ss.state.Backups {
+		if b.Run > last {
+			last = b.Run
+		}
+	}
+
+	var records []backupRecord
+	for _, b := range ss.state.Backups {
+		if b.Run == last {
+			records = append(records, b)
+		}
+	}
+	return records
+}
+
+// Forget records of the backups taken in a run
+func (ss *stateStore) removeBackups(run string) error {
+	if ss == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	backups := ss.state.Backups[:0]
+	for _, b := range ss.state.Backups {
+		if b.Run != run {
+			backups = append(backups, b)
+		}
+	}
+	ss.state.Backups = backups
+	return ss.save()
+}
+
+// Build the checkpoint of a copy that is about to start
+func newCheckpoint(ranges []idRange) (checkpoint, error) {
+	var cp checkpoint
+	for _, r := range ranges {
+		min, err := encodeID(r.min)
+		if err != nil {
+			return cp, err
+		}
+		max, err := encodeID(r.max)
+		if err != nil {
+			return cp, err
+		}
+		cp.Ranges = append(cp.Ranges, rangeCheckpoint{Min: min, Max: max})
+	}
+
+	return cp, nil
+}
+
+// _id range of a range checkpoint and the last _id written in it
+func (rc rangeCheckpoint) idRange() (idRange, bson.RawValue, error) {
+	var r idRange
+	var err error
+	if r.min, err = decodeID(rc.Min); err != nil {
+		return r, bson.RawValue{}, err
+	}
+	if r.max, err = decodeID(rc.Max); err != nil {
+		return r, bson.RawValue{}, err
+	}
+	last, err := decodeID(rc.Last)
+
+	return r, last, err
+}
+
+// Encode _id value as canonical extended JSON so its type is kept, an unset value is an empty string
+func encodeID(v bson.RawValue) (string, error) {
+	if isEmptyValue(v) {
+		return "", nil
+	}
+
+	out, err := bson.MarshalExtJSON(bson.D{{Key: "_id", Value: v}}, true, false)
+	return string(out), err
+}
+
+// Decode _id value encoded by encodeID
+func decodeID(s string) (bson.RawValue, error) {
+	if s == "" {
+		return bson.RawValue{}, nil
+	}
+
+	var doc bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(s), true, &doc); err != nil {
+		return bson.RawValue{}, err
+	}
+
+	return doc.Lookup("_id"), nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLoadStateStore_FileNotFound(t *testing.T) {
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("expected missing state file to be ignored, got %v", err)
+	}
+	if _, ok := ss.checkpoint("key"); ok {
+		t.Error("expected no checkpoints in new state")
+	}
+}
+
+func TestLoadStateStore_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadStateStore(path); err == nil {
+		t.Error("expected error for invalid state file")
+	}
+}
+
+func TestStateStore_Checkpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	ss, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := newCheckpoint(partitionRanges([]bson.RawValue{int32Value(10)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.setCheckpoint("key", cp); err != nil {
+		t.Fatalf("failed to save checkpoint: %v", err)
+	}
+	if err := ss.advanceCheckpoint("key", 0, int32Value(5), false); err != nil {
+		t.Fatalf("failed to advance checkpoint: %v", err)
+	}
+	if err := ss.advanceCheckpoint("key", 1, bson.RawValue{}, true); err != nil {
+		t.Fatalf("failed to advance checkpoint: %v", err)
+	}
+
+	// Progress is read back from the file by a new store
+	reloaded, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, ok := reloaded.checkpoint("key")
+	if !ok || len(cp.Ranges) != 2 {
+		t.Fatalf("expected checkpoint with 2 ranges, got %+v", cp)
+	}
+	r, last, err := cp.Ranges[0].idRange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEmptyValue(r.min) || !r.max.Equal(int32Value(10)) || !last.Equal(int32Value(5)) || cp.Ranges[0].Done {
+		t.Errorf("unexpected first range %+v", cp.Ranges[0])
+	}
+	if !cp.Ranges[1].Done {
+		t.Error("expected second range to be done")
+	}
+
+	if err := reloaded.removeCheckpoint("key"); err != nil {
+		t.Fatalf("failed to remove checkpoint: %v", err)
+	}
+	if _, ok := reloaded.checkpoint("key"); ok {
+		t.Error("expected checkpoint to be removed")
+	}
+}
+
+func TestStateStore_Backups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	ss, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := backupRecord{Run: "20261017T090000Z", Host: "prod", Database: "shop", Collection: "orders"}
+	second := backupRecord{Run: "20261017T100000Z", Host: "prod", Database: "shop", Collection: "orders"}
+	third := backupRecord{Run: "20261017T100000Z", Host: "prod", Database: "shop", Collection: "customers"}
+	for _, r := range []backupRecord{first, second, third} {
+		if err := ss.addBackup(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !ss.hasBackup(second) {
+		t.Error("expected backup to be found in its run")
+	}
+	if ss.hasBackup(backupRecord{Run: "20261017T110000Z", Host: "prod", Database: "shop", Collection: "orders"}) {
+		t.Error("expected no backup in a run that took none")
+	}
+
+	// Backups are kept in the state file
+	loaded, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := loaded.lastRunBackups()
+	if len(last) != 2 || last[0] != second || last[1] != third {
+		t.Errorf("expected backups of the last run, got %+v", last)
+	}
+
+	if err := loaded.removeBackups(second.Run); err != nil {
+		t.Fatal(err)
+	}
+	last = loaded.lastRunBackups()
+	if len(last) != 1 || last[0] != first {
+		t.Errorf("expected backups of the run before once rolled back, got %+v", last)
+	}
+}
+
+func TestStateStore_SyncCheckpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	ss, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ss.setSyncCheckpoint("key", syncCheckpoint{Token: `{"_data":"8263"}`, Copied: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, ok := loaded.syncCheckpoint("key")
+	if !ok || sc.Token != `{"_data":"8263"}` || !sc.Copied || sc.Updated.IsZero() {
+		t.Errorf("expected sync checkpoint to be saved, got %+v", sc)
+	}
+}
+
+func TestStateStore_Watermarks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	ss, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ss.setWatermark("key", watermark{Field: "seq", Value: `{"_id":{"$numberInt":"9"}}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, ok := loaded.watermark("key")
+	if !ok || w.Field != "seq" || w.Value != `{"_id":{"$numberInt":"9"}}` || w.Updated.IsZero() {
+		t.Errorf("expected watermark to be saved, got %+v", w)
+	}
+}
+
+func TestStateStore_Nil(t *testing.T) {
+	var ss *stateStore
+	if _, ok := ss.checkpoint("key"); ok {
+		t.Error("expected no checkpoint from nil store")
+	}
+	if err := ss.setCheckpoint("key", checkpoint{}); err != nil {
+		t.Errorf("expected nil store to ignore checkpoints, got %v", err)
+	}
+	if err := ss.removeCheckpoint("key"); err != nil {
+		t.Errorf("expected nil store to ignore checkpoints, got %v", err)
+	}
+}
+
+func TestEncodeID(t *testing.T) {
+	_, raw, err := bson.MarshalValue(primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []bson.RawValue{
+		int32Value(42),
+		bson.RawValue{Type: bson.TypeObjectID, Value: raw},
+	}
+	for _, id := range ids {
+		encoded, err := encodeID(id)
+		if err != nil {
+			t.Fatalf("failed to encode %v: %v", id, err)
+		}
+		decoded, err := decodeID(encoded)
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", encoded, err)
+		}
+		if !decoded.Equal(id) {
+			t.Errorf("expected %v, got %v", id, decoded)
+		}
+	}
+
+	if encoded, _ := encodeID(bson.RawValue{}); encoded != "" {
+		t.Errorf("expected unset _id to be empty, got %s", encoded)
+	}
+}
@@ -3,24 +3,53 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Operations the terminal UI and CLI use, new storage is made with newStorage
 type Storage interface {
-	NewStorage(targetURI string, sourceURI string) storage
-	copy(sourceCollection string, targetCollection string, sourceDatabase string, targetDatabase string)
-	getTargetDatabases() ([]string, error)
-	getSourceDatabases() ([]string, error)
-	getTargetCollections(databaseName string) ([]collection, error)
-	getSourceCollections(databaseName string) ([]collection, error)
+	copy(ctx context.Context, spec copySpec) (copyReport, error)
+	getTargetDatabases(ctx context.Context) ([]string, error)
+	getSourceDatabases(ctx context.Context) ([]string, error)
+	getTargetCollections(ctx context.Context, databaseName string) ([]collection, error)
+	getSourceCollections(ctx context.Context, databaseName string) ([]collection, error)
 }
 
+var _ Storage = storage{}
+
 type storage struct {
-	targetURI string
-	sourceURI string
+	targetURI  string
+	sourceURI  string
+	batchSize  int // Maximum number of documents written to the target per bulk write
+	batchBytes int // Maximum size in bytes of documents written to the target per bulk write
+
+	partitionWorkers      int   // Number of workers copying _id ranges of a single collection at once
+	partitionMinDocuments int64 // Collections with fewer documents than this are copied by a single worker
+
+	state *stateStore // Checkpoints of copies that can be resumed, not saved when nil
+
+	verify verifySettings // Checks run after a copy to compare the target with the source
+
+	run       string // Id of this run, backups taken in it are rolled back together
+	backupDir string // Local directory archive backups are written to
+
+	pool         poolSettings  // Connection pool limits of the source and target clients
+	sourceClient *mongo.Client // Long-lived client shared by every operation, nil until connected
+	targetClient *mongo.Client // Long-lived client shared by every operation, nil until connected
+}
+
+// Connection pool limits, the driver defaults are used for limits that are not set
+type poolSettings struct {
+	maxSize     uint64
+	minSize     uint64
+	maxConnIdle time.Duration
 }
 
 // Initialize new storage instance
@@ -28,70 +57,597 @@ func newStorage(targetURI string, sourceURI string) storage {
 	var s storage
 	s.targetURI = targetURI
 	s.sourceURI = sourceURI
+	s.batchSize = defaultBatchSize
+	s.batchBytes = defaultBatchBytes
+	s.partitionWorkers = defaultPartitionWorkers
+	s.partitionMinDocuments = defaultPartitionMinDocuments
+	s.verify = verifySettings{samples: defaultVerifySamples, reportFile: defaultVerifyReportFile}
+	s.run = newRunID()
+	s.backupDir = defaultBackupDir
 	return s
 }
 
-// Copy data from given source database/collection to target database/collection deleting all data in target first.
-func (s storage) copy(sourceCollection string, targetCollection string, sourceDatabase string, targetDatabase string) error {
-	sOptions := options.Client().ApplyURI(s.sourceURI)
-	sClient, err := mongo.Connect(context.Background(), sOptions)
+// Initialize new storage instance for the servers and settings in config
+func newStorageFromConfig(c config) (storage, error) {
+	state, err := loadStateStore(c.StateFile)
 	if err != nil {
-		return err
+		return storage{}, fmt.Errorf("failed to load state file: %w", err)
+	}
+
+	return newStorage(c.Target, c.Source).
+		withBatching(c.BatchSize, c.BatchBytes).
+		withPartitioning(c.PartitionWorkers, c.PartitionMinDocuments).
+		withPool(c.MaxPoolSize, c.MinPoolSize, c.MaxConnIdleSeconds).
+		withVerify(c.VerifySamples, c.VerifyReportFile).
+		withBackupDir(c.BackupDir).
+		withState(state), nil
+}
+
+// Set the source and target servers to connect to. Clients connected to the previous servers are
+// left to the caller to disconnect.
+func (s storage) withServers(targetURI string, sourceURI string) storage {
+	s.targetURI = targetURI
+	s.sourceURI = sourceURI
+	s.sourceClient = nil
+	s.targetClient = nil
+	return s
+}
+
+// Set the connection pool limits of the clients, keeping the driver defaults for limits that are not set
+func (s storage) withPool(maxSize int, minSize int, maxConnIdleSeconds int) storage {
+	s.pool = poolSettings{
+		maxSize:     uint64(max(maxSize, 0)),
+		minSize:     uint64(max(minSize, 0)),
+		maxConnIdle: time.Duration(max(maxConnIdleSeconds, 0)) * time.Second,
+	}
+	return s
+}
+
+// Client options for a server with the pool limits applied
+func (s storage) clientOptions(uri string) *options.ClientOptions {
+	opts := options.Client().ApplyURI(uri)
+	if s.pool.maxSize > 0 {
+		opts.SetMaxPoolSize(s.pool.maxSize)
+	}
+	if s.pool.minSize > 0 {
+		opts.SetMinPoolSize(s.pool.minSize)
+	}
+	if s.pool.maxConnIdle > 0 {
+		opts.SetMaxConnIdleTime(s.pool.maxConnIdle)
+	}
+
+	return opts
+}
+
+// Create the long-lived source and target clients used by every operation until disconnect is called.
+// Connections are opened by the driver when first needed.
+func (s storage) connect() (storage, error) {
+	source, err := mongo.Connect(context.Background(), s.clientOptions(s.sourceURI))
+	if err != nil {
+		return s, fmt.Errorf("failed to connect to source server: %w", err)
+	}
+	target, err := mongo.Connect(context.Background(), s.clientOptions(s.targetURI))
+	if err != nil {
+		source.Disconnect(context.Background())
+		return s, fmt.Errorf("failed to connect to target server: %w", err)
+	}
+
+	s.sourceClient = source
+	s.targetClient = target
+	return s, nil
+}
+
+// Close the long-lived clients, waiting for operations in progress until the context is done
+func (s storage) disconnect(ctx context.Context) error {
+	var errs []error
+	if s.sourceClient != nil {
+		errs = append(errs, s.sourceClient.Disconnect(ctx))
+	}
+	if s.targetClient != nil {
+		errs = append(errs, s.targetClient.Disconnect(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Get the long-lived source client, or a client for a single operation if storage is not connected.
+// The returned function releases the client once the operation is done.
+func (s storage) source(ctx context.Context) (*mongo.Client, func(), error) {
+	return s.client(ctx, s.sourceClient, s.sourceURI)
+}
+
+// Get the long-lived target client, or a client for a single operation if storage is not connected.
+// The returned function releases the client once the operation is done.
+func (s storage) target(ctx context.Context) (*mongo.Client, func(), error) {
+	return s.client(ctx, s.targetClient, s.targetURI)
+}
+
+func (s storage) client(ctx context.Context, pooled *mongo.Client, uri string) (*mongo.Client, func(), error) {
+	if pooled != nil {
+		return pooled, func() {}, nil
 	}
-	defer sClient.Disconnect(context.Background())
 
-	tOptions := options.Client().ApplyURI(s.targetURI)
-	tClient, err := mongo.Connect(context.Background(), tOptions)
+	client, err := mongo.Connect(ctx, s.clientOptions(uri))
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	return client, func() { client.Disconnect(context.Background()) }, nil
+}
+
+// Set where checkpoints of copies are saved
+func (s storage) withState(state *stateStore) storage {
+	s.state = state
+	return s
+}
+
+// Set the bulk write limits used when copying, keeping the defaults for limits that are not set
+func (s storage) withBatching(batchSize int, batchBytes int) storage {
+	if batchSize > 0 {
+		s.batchSize = batchSize
 	}
-	defer tClient.Disconnect(context.Background())
+	if batchBytes > 0 {
+		s.batchBytes = batchBytes
+	}
+	return s
+}
+
+// Details of a single collection copy
+type copySpec struct {
+	sourceDatabase   string
+	sourceCollection string
+	targetDatabase   string
+	targetCollection string
+	mode             writeMode // How documents are written to the target collection
+	key              string    // Field matching source and target documents in upsert and insert-missing modes
+	query            copyQuery // Subset of source documents to copy
+	resume           bool      // Continue from the saved checkpoint instead of starting again
+
+	progress func(copyProgress) // Called with the documents copied so far after each bulk write, if set
+	pause    *pauser            // Holds the copy between bulk writes while paused, if set
+
+	indexes indexCopy  // When source indexes are recreated on the target, if at all
+	create  bool       // Create the target collection with the source collection's options first
+	backup  backupMode // Where target documents are saved before a destructive copy deletes them
+	staging bool       // Load a staging collection that replaces the target in one rename once complete
+
+	transaction bool // Delete and write the target documents in one transaction where the target supports it
+	sync        bool // Keep applying source changes to the target after the copy, see storage.sync
+
+	watermark string            // Increasing field an incremental copy copies documents above the last copy's highest value of, see storage.incrementalQuery
+	transform transformPipeline // Steps applied to every source document before it is written
+}
+
+// Outcome of a copy besides the documents written
+type copyReport struct {
+	indexes *indexReport // Indexes copied to the target, nil when indexes are not copied
+	notice  string       // How the copy differed from what was asked for, such as copying without a transaction
+}
+
+// Set how large collections are split between workers, keeping the defaults for values that are not set
+func (s storage) withPartitioning(workers int, minDocuments int64) storage {
+	if workers > 0 {
+		s.partitionWorkers = workers
+	}
+	if minDocuments > 0 {
+		s.partitionMinDocuments = minDocuments
+	}
+	return s
+}
+
+// Copy data from given source database/collection to target database/collection.
+// Data in the target is deleted first when the write mode is replace, unless a stopped copy is resumed.
+func (s storage) copy(ctx context.Context, spec copySpec) (copyReport, error) {
+	var report copyReport
+
+	sClient, releaseSource, err := s.source(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseSource()
+
+	tClient, releaseTarget, err := s.target(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseTarget()
 
 	// Get source and target collections
-	sc := sClient.Database(sourceDatabase).Collection(sourceCollection)
-	tc := tClient.Database(targetDatabase).Collection(targetCollection)
+	sc := sClient.Database(spec.sourceDatabase).Collection(spec.sourceCollection)
+	tc := tClient.Database(spec.targetDatabase).Collection(spec.targetCollection)
 
-	// Check there are documents to move
-	count, err := s.getRecordCount(sClient, sourceDatabase, sourceCollection)
-	if count == 0 {
-		return errors.New("no records in source collection to copy")
-	} else if err != nil {
+	query, err := spec.query.parse()
+	if err != nil {
+		return report, err
+	}
+	if _, err := spec.transform.parse(); err != nil {
+		return report, err
+	}
+	if spec.transaction {
+		if err := spec.validTransaction(); err != nil {
+			return report, err
+		}
+	}
+	if spec.watermark != "" {
+		if err := spec.validIncremental(); err != nil {
+			return report, err
+		}
+	}
+
+	// Staged copies write to a staging collection that replaces the target once it is complete.
+	// Views have no documents of their own, creating the target view is the whole copy.
+	wc := tc
+	if spec.staging {
+		staging, view, err := s.prepareStaging(ctx, sc, tc, spec)
+		if err != nil || view {
+			return report, err
+		}
+		wc = staging
+	} else if spec.create {
+		view, err := s.createCollection(ctx, sc, tc)
+		if err != nil || view {
+			return report, err
+		}
+	}
+
+	// Incremental copies only copy the documents written since the last copy
+	if spec.watermark != "" {
+		if query, err = s.incrementalQuery(ctx, sc, spec, query); err != nil {
+			return report, err
+		}
+	}
+
+	// Check there are documents to move, a new target collection may stay empty and an incremental copy
+	// may have nothing new
+	count, err := s.getRecordCount(ctx, sClient, spec.sourceDatabase, spec.sourceCollection, query.filter, query.countOptions())
+	if err != nil {
+		return report, err
+	} else if count == 0 && !spec.create && spec.watermark == "" {
+		return report, errors.New("no records in source collection to copy")
+	} else if count == 0 {
+		if spec.watermark != "" {
+			report.notice = noNewDocumentsNotice
+		}
+		if spec.indexes != indexesNone {
+			indexes, err := s.copyIndexes(ctx, sc, wc)
+			report.indexes = &indexes
+			if err != nil {
+				return report, err
+			}
+		}
+		if spec.staging {
+			return report, s.promoteStaging(ctx, tc, wc, spec)
+		}
+		return report, s.advanceWatermark(spec)
+	}
+
+	// Transactional copies fall back to a normal copy on targets without transactions
+	transaction := false
+	if spec.transaction {
+		if transaction, err = supportsTransactions(ctx, tClient); err != nil {
+			return report, fmt.Errorf("failed to check if the target supports transactions: %w", err)
+		} else if !transaction {
+			report.notice = noTransactionNotice
+		}
+	}
+
+	key := s.copyKey(spec)
+	var ranges []rangeCopy
+	if spec.resume {
+		if query.excludesID() || spec.transform.changesID() {
+			return report, errors.New("copies that leave out or change the _id cannot be resumed")
+		}
+		if ranges, err = s.resumeRanges(key); err != nil {
+			return report, err
+		}
+	} else if !transaction {
+		if ranges, err = s.startRanges(ctx, sc, wc, key, spec, query, count); err != nil {
+			return report, err
+		}
+	}
+
+	// Resumed copies only have the documents left in their ranges to copy
+	total := count
+	if spec.resume {
+		total = 0
+		for _, r := range ranges {
+			remaining, err := s.getRecordCount(ctx, sClient, spec.sourceDatabase, spec.sourceCollection, query.and(r.filter(r.last)), query.countOptions())
+			if err != nil {
+				return report, err
+			}
+			total += remaining
+		}
+	}
+
+	if spec.indexes == indexesBefore {
+		indexes, err := s.copyIndexes(ctx, sc, wc)
+		report.indexes = &indexes
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if transaction {
+		if err := s.copyTransaction(ctx, sc, wc, spec, query, count); err != nil {
+			return report, err
+		}
+	} else if err := s.copyRanges(ctx, sc, wc, key, spec, query, ranges, total); err != nil {
+		return report, err
+	}
+
+	if spec.indexes == indexesAfter {
+		indexes, err := s.copyIndexes(ctx, sc, wc)
+		report.indexes = &indexes
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if spec.staging {
+		if err := s.promoteStaging(ctx, tc, wc, spec); err != nil {
+			return report, err
+		}
+	}
+
+	if err := s.advanceWatermark(spec); err != nil {
+		return report, err
+	}
+	return report, s.state.removeCheckpoint(key)
+}
+
+// Copy the ranges of a copy at once, saving progress through checkpointed ranges after each bulk write
+func (s storage) copyRanges(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection, key string, spec copySpec, query parsedQuery, ranges []rangeCopy, total int64) error {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	// Paused copies hold their cursors open, which the server would otherwise close after 10 idle minutes.
+	// Servers still end the cursor's session after about 30 idle minutes, longer pauses fail and can be resumed.
+	opts := query.findOptions().SetNoCursorTimeout(true)
+	if ranges[0].checkpointed {
+		opts.SetSort(bson.D{{Key: "_id", Value: 1}})
+	}
+
+	var copied atomic.Int64
+	spec.report(copyProgress{total: total})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(ranges))
+	for _, r := range ranges {
+		wg.Add(1)
+		go func(r rangeCopy) {
+			defer wg.Done()
+			errs <- s.copyRange(ctx, sc, tc, query.and(r.filter(r.last)), opts, spec, func(written int, last bson.RawValue, done bool) error {
+				spec.report(copyProgress{copied: copied.Add(int64(written)), total: total})
+				if r.checkpointed {
+					return s.state.advanceCheckpoint(key, r.index, last, done)
+				}
+				return nil
+			})
+		}(r)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// _id range copied by one worker, and how far a resumed copy already got through it
+type rangeCopy struct {
+	idRange
+	index        int           // Position of the range in the checkpoint
+	last         bson.RawValue // _id of the last document already written to the target
+	checkpointed bool          // Is progress through the range saved
+}
+
+// Key of a copy in the state file
+func (s storage) copyKey(spec copySpec) string {
+	key := fmt.Sprintf("%s/%s.%s -> %s/%s.%s",
+		serverHost(s.sourceURI), spec.sourceDatabase, spec.sourceCollection,
+		serverHost(s.targetURI), spec.targetDatabase, spec.targetCollection)
+
+	// Staged copies write to the staging collection, so only they can resume from their checkpoints
+	if spec.staging {
+		key += " (staged)"
+	}
+	return key
+}
+
+// Check if a stopped copy has a checkpoint it can be resumed from
+func (s storage) resumable(spec copySpec) bool {
+	if _, ok := s.state.checkpoint(s.copyKey(spec)); ok {
+		return true
+	}
+
+	// Syncs continue from their resume token
+	_, ok := s.state.syncCheckpoint(s.copyKey(spec))
+	return spec.sync && ok
+}
+
+// Ranges of a new copy, emptying the target first when the write mode is destructive.
+// Large collections are split into _id ranges that are copied by several workers at once.
+// Sorted or limited queries have to be read by a single cursor, so they are neither split nor checkpointed.
+func (s storage) startRanges(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection, key string, spec copySpec, query parsedQuery, count int64) ([]rangeCopy, error) {
+	ranges := []idRange{{}}
+	checkpointed := false
+	if !query.ordered() {
+		if s.partitionWorkers > 1 && count >= s.partitionMinDocuments {
+			bounds, err := s.partitionBounds(ctx, sc, query.filter, count, s.partitionWorkers)
+			if err != nil {
+				return nil, err
+			}
+			ranges = partitionRanges(bounds)
+		}
+
+		// Checkpoints record the _id of the last document written, which projections and transforms can take away
+		if s.state != nil && !query.excludesID() && !spec.transform.changesID() {
+			uniform, err := s.uniformIDs(ctx, sc, bson.D{})
+			if err != nil {
+				return nil, err
+			}
+			checkpointed = uniform
+		}
+	}
+
+	// Delete all documents in target, saving them first if asked to
+	if spec.mode.destructive() {
+		if err := s.backupTarget(ctx, tc, spec); err != nil {
+			return nil, err
+		}
+		if _, err := tc.DeleteMany(ctx, bson.D{}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace any checkpoint left by an earlier copy
+	if checkpointed {
+		cp, err := newCheckpoint(ranges)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.state.setCheckpoint(key, cp); err != nil {
+			return nil, err
+		}
+	} else if err := s.state.removeCheckpoint(key); err != nil {
+		return nil, err
+	}
+
+	copies := make([]rangeCopy, len(ranges))
+	for i, r := range ranges {
+		copies[i] = rangeCopy{idRange: r, index: i, checkpointed: checkpointed}
+	}
+
+	return copies, nil
+}
+
+// Ranges of a stopped copy that still have documents to copy
+func (s storage) resumeRanges(key string) ([]rangeCopy, error) {
+	cp, ok := s.state.checkpoint(key)
+	if !ok {
+		return nil, errors.New("no checkpoint to resume copy from")
+	}
+
+	var copies []rangeCopy
+	for i, rc := range cp.Ranges {
+		if rc.Done {
+			continue
+		}
+		r, last, err := rc.idRange()
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint: %w", err)
+		}
+		copies = append(copies, rangeCopy{idRange: r, index: i, last: last, checkpointed: true})
+	}
+
+	// Every range was copied but the checkpoint was not removed
+	if len(copies) == 0 {
+		return nil, s.state.removeCheckpoint(key)
+	}
+
+	return copies, nil
+}
+
+// Pass progress of the copy to its progress function, if set
+func (spec copySpec) report(p copyProgress) {
+	if spec.progress != nil {
+		spec.progress(p)
+	}
+}
+
+// Copy documents matching filter from source collection to target collection in batches.
+// After each batch is written, flushed is called with the number of documents written and the _id of the last one.
+func (s storage) copyRange(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection, filter bson.D, opts *options.FindOptions, spec copySpec, flushed func(written int, last bson.RawValue, done bool) error) error {
+	transform, err := spec.transform.parse()
+	if err != nil {
 		return err
 	}
 
 	// Find documents in the source collection
-	cursor, err := sc.Find(context.Background(), bson.D{})
+	cursor, err := sc.Find(ctx, filter, opts)
 	if err != nil {
 		return err
 	}
 	defer cursor.Close(context.Background())
 
-	// Delete all documents in target
-	tc.DeleteMany(context.Background(), bson.D{})
+	// Write the batch and report the _id of its last document
+	var last bson.RawValue
+	flush := func(batch *writeBatch, done bool) error {
+		if err := spec.pause.wait(ctx); err != nil {
+			return err
+		}
+		written := batch.len()
+		if err := s.flush(ctx, tc, batch); err != nil {
+			return err
+		}
+		return flushed(written, last, done)
+	}
+
+	// A stopped copy may have written documents after its last checkpoint, so resumed copies upsert on _id
+	// where they would insert and write those documents again
+	mode, key := spec.mode, spec.key
+	if spec.resume && !mode.keyed() {
+		mode, key = modeUpsert, defaultWriteKey
+	}
 
-	// Iterate through documents and insert into target collection
-	for cursor.Next(context.Background()) {
-		var doc interface{}
-		if err := cursor.Decode(&doc); err != nil {
+	// Iterate through documents buffering them into batches that are written to the target collection
+	batch := newWriteBatch(s.batchSize, s.batchBytes)
+	for cursor.Next(ctx) {
+		doc, raw, err := transform.document(cursor.Current)
+		if err != nil {
 			return err
 		}
 
-		var opts = options.InsertOneOptions{}
-		_, err := tc.InsertOne(context.Background(), doc, &opts)
+		model, err := mode.writeModel(doc, raw, key)
 		if err != nil {
 			return err
 		}
+
+		size := len(raw)
+		if !batch.fits(size) {
+			if err := flush(batch, false); err != nil {
+				return err
+			}
+		}
+		batch.add(model, size)
+
+		if id, err := cursor.Current.LookupErr("_id"); err == nil {
+			last = bson.RawValue{Type: id.Type, Value: append([]byte(nil), id.Value...)}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	return flush(batch, true)
+}
+
+// Write all buffered documents to the target collection with a single unordered bulk write and empty the batch.
+func (s storage) flush(ctx context.Context, tc *mongo.Collection, batch *writeBatch) error {
+	if batch.len() == 0 {
+		return nil
 	}
 
+	opts := options.BulkWrite().SetOrdered(false)
+	if _, err := tc.BulkWrite(ctx, batch.models, opts); err != nil {
+		return err
+	}
+
+	batch.reset()
 	return nil
 }
 
-func (s storage) getRecordCount(client *mongo.Client, databaseName string, collectionName string) (int64, error) {
+func (s storage) getRecordCount(ctx context.Context, client *mongo.Client, databaseName string, collectionName string, filter bson.D, opts ...*options.CountOptions) (int64, error) {
 	// Get source and target collections
 	sc := client.Database(databaseName).Collection(collectionName)
 
 	// Check there are documents to move
-	count, err := sc.CountDocuments(context.Background(), bson.D{})
+	count, err := sc.CountDocuments(ctx, filter, opts...)
 	if err != nil {
 		return 0, err
 	}
@@ -100,18 +656,17 @@ func (s storage) getRecordCount(client *mongo.Client, databaseName string, colle
 }
 
 // Get collections from target database.
-func (s storage) getTargetCollections(databaseName string) ([]collection, error) {
-	options := options.Client().ApplyURI(s.targetURI)
-	client, err := mongo.Connect(context.Background(), options)
+func (s storage) getTargetCollections(ctx context.Context, databaseName string) ([]collection, error) {
+	client, release, err := s.target(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Disconnect(context.Background())
+	defer release()
 
 	db := client.Database(databaseName)
 
 	// Retrieve collection names
-	c, err := db.ListCollectionNames(context.Background(), bson.D{})
+	c, err := db.ListCollectionNames(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +674,7 @@ func (s storage) getTargetCollections(databaseName string) ([]collection, error)
 	var collections []collection
 
 	for _, name := range c {
-		count, err := s.getRecordCount(client, databaseName, name)
+		count, err := s.getRecordCount(ctx, client, databaseName, name, bson.D{})
 		if err != nil {
 			return collections, err
 		}
@@ -135,33 +690,37 @@ func (s storage) getTargetCollections(databaseName string) ([]collection, error)
 }
 
 // Get collections from source database.
-func (s storage) getSourceCollections(databaseName string) ([]collection, error) {
-	options := options.Client().ApplyURI(s.sourceURI)
-	client, err := mongo.Connect(context.Background(), options)
+func (s storage) getSourceCollections(ctx context.Context, databaseName string) ([]collection, error) {
+	client, release, err := s.source(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Disconnect(context.Background())
+	defer release()
 
 	db := client.Database(databaseName)
 
-	// Retrieve collection names
-	c, err := db.ListCollectionNames(context.Background(), bson.D{})
+	// Retrieve collection names and types
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
 
 	var collections []collection
 
-	for _, name := range c {
-		count, err := s.getRecordCount(client, databaseName, name)
-		if err != nil {
-			return collections, err
+	for _, spec := range specs {
+		var collection collection
+		collection.name = spec.Name
+		collection.view = spec.Type == "view"
+
+		// Counting a view runs its whole pipeline, so views are listed without a count
+		if !collection.view {
+			count, err := s.getRecordCount(ctx, client, databaseName, spec.Name, bson.D{})
+			if err != nil {
+				return collections, err
+			}
+			collection.count = count
 		}
 
-		var collection collection
-		collection.count = count
-		collection.name = name
 		collections = append(collections, collection)
 	}
 
@@ -169,15 +728,14 @@ func (s storage) getSourceCollections(databaseName string) ([]collection, error)
 }
 
 // Get all databases from target server provided in config.
-func (s storage) getTargetDatabases() ([]string, error) {
-	options := options.Client().ApplyURI(s.targetURI)
-	client, err := mongo.Connect(context.Background(), options)
+func (s storage) getTargetDatabases(ctx context.Context) ([]string, error) {
+	client, release, err := s.target(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Disconnect(context.Background())
+	defer release()
 
-	result, err := client.ListDatabaseNames(context.TODO(), bson.D{})
+	result, err := client.ListDatabaseNames(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
@@ -186,15 +744,14 @@ func (s storage) getTargetDatabases() ([]string, error) {
 }
 
 // Get all databases from source server provided in config.
-func (s storage) getSourceDatabases() ([]string, error) {
-	options := options.Client().ApplyURI(s.sourceURI)
-	client, err := mongo.Connect(context.Background(), options)
+func (s storage) getSourceDatabases(ctx context.Context) ([]string, error) {
+	client, release, err := s.source(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Disconnect(context.Background())
+	defer release()
 
-	result, err := client.ListDatabaseNames(context.TODO(), bson.D{})
+	result, err := client.ListDatabaseNames(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Where a sync got to in the source change stream, so it can be resumed after it stopped
+type syncCheckpoint struct {
+	Token   string    `json:"token"`   // Resume token of the last change applied to the target, as extended JSON
+	Copied  bool      `json:"copied"`  // Has the initial copy finished
+	Updated time.Time `json:"updated"` // When the token was last saved
+}
+
+// Change stream event of a source collection
+type changeEvent struct {
+	OperationType string              `bson:"operationType"`
+	DocumentKey   bson.Raw            `bson:"documentKey"`
+	FullDocument  bson.RawValue       `bson:"fullDocument"` // Document after the change, null once it has been deleted
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+}
+
+// Check a sync only uses options it can keep applying to the target. Changes are matched on _id
+// and apply to whole documents, so neither other keys nor queries can be followed.
+func (spec copySpec) validSync() error {
+	if !spec.query.empty() {
+		return errors.New("synced copies cannot have a filter, sort, projection or limit")
+	} else if spec.mode.keyed() && spec.key != "" && spec.key != defaultWriteKey {
+		return errors.New("synced copies must match documents on _id")
+	} else if spec.transform.changesID() {
+		return errors.New("synced copies cannot transform the _id")
+	}
+
+	return nil
+}
+
+// Copy a collection and keep applying the changes made to the source to the target until ctx is cancelled,
+// which stops the sync without error once the initial copy has finished. The change stream is opened before
+// the initial copy, so changes made while copying are applied after it. initial runs the initial copy,
+// such as in a scheduler slot. Resumed syncs continue from their saved resume token.
+func (s storage) sync(ctx context.Context, spec copySpec, initial func(func() error) error) (copyReport, error) {
+	var report copyReport
+	if err := spec.validSync(); err != nil {
+		return report, err
+	}
+	key := s.copyKey(spec)
+
+	sClient, releaseSource, err := s.source(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseSource()
+
+	tClient, releaseTarget, err := s.target(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseTarget()
+
+	sc := sClient.Database(spec.sourceDatabase).Collection(spec.sourceCollection)
+	tc := tClient.Database(spec.targetDatabase).Collection(spec.targetCollection)
+
+	transform, err := spec.transform.parse()
+	if err != nil {
+		return report, err
+	}
+
+	saved, resumed := s.state.syncCheckpoint(key)
+	resumed = resumed && spec.resume
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup).SetMaxAwaitTime(time.Second)
+	if resumed {
+		token, err := decodeResumeToken(saved.Token)
+		if err != nil {
+			return report, fmt.Errorf("invalid sync resume token: %w", err)
+		}
+		opts.SetResumeAfter(token)
+	}
+
+	cs, err := sc.Watch(ctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		return report, fmt.Errorf("failed to watch source collection, syncing needs a replica set or sharded cluster: %w", err)
+	}
+	defer cs.Close(context.Background())
+
+	if !resumed {
+		if saved.Token, err = encodeResumeToken(cs.ResumeToken()); err != nil {
+			return report, err
+		}
+		saved.Copied = false
+		if err := s.state.setSyncCheckpoint(key, saved); err != nil {
+			return report, err
+		}
+	}
+
+	// Documents copied are remembered to show with the changes applied after the initial copy
+	var copied atomic.Int64
+	if !saved.Copied {
+		copySpec := spec
+		_, checkpointed := s.state.checkpoint(key)
+		copySpec.resume = resumed && checkpointed
+		copySpec.progress = func(p copyProgress) {
+			copied.Store(p.total)
+			spec.report(p)
+		}
+		err := initial(func() error {
+			var err error
+			report, err = s.copy(ctx, copySpec)
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+
+		saved.Copied = true
+		if err := s.state.setSyncCheckpoint(key, saved); err != nil {
+			return report, err
+		}
+	}
+
+	return report, s.applyChanges(ctx, cs, tc, spec, transform, key, saved, copied.Load())
+}
+
+// Apply source changes to the target as they arrive, saving the resume token whenever the stream has caught up
+func (s storage) applyChanges(ctx context.Context, cs *mongo.ChangeStream, tc *mongo.Collection, spec copySpec, transform parsedTransform, key string, saved syncCheckpoint, copied int64) error {
+	var changes int64
+	var lag time.Duration
+	for {
+		// Changes wait in the stream while the sync is paused
+		if err := spec.pause.wait(ctx); err != nil {
+			return nil
+		}
+
+		if cs.TryNext(ctx) {
+			var event changeEvent
+			if err := cs.Decode(&event); err != nil {
+				return err
+			}
+			if err := applyChange(ctx, tc, event, transform); err != nil {
+				return fmt.Errorf("failed to apply %s of %s: %w", event.OperationType, event.DocumentKey, err)
+			}
+			changes++
+			lag = max(time.Since(time.Unix(int64(event.ClusterTime.T), 0)), 0)
+
+			// Changes already read are applied before the token is saved
+			if cs.RemainingBatchLength() > 0 {
+				continue
+			}
+		} else if err := cs.Err(); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("source change stream failed: %w", err)
+		} else {
+			lag = 0
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		token, err := encodeResumeToken(cs.ResumeToken())
+		if err != nil {
+			return err
+		}
+		if token != saved.Token {
+			saved.Token = token
+			if err := s.state.setSyncCheckpoint(key, saved); err != nil {
+				return err
+			}
+		}
+		spec.report(copyProgress{copied: copied, total: copied, syncing: true, changes: changes, lag: lag})
+	}
+}
+
+// Apply one source change to the target, matching the document on _id and transforming written documents
+func applyChange(ctx context.Context, tc *mongo.Collection, event changeEvent, transform parsedTransform) error {
+	filter := bson.D{{Key: "_id", Value: event.DocumentKey.Lookup("_id")}}
+
+	switch event.OperationType {
+	case "insert", "update", "replace":
+		// Updates are looked up when read, a document deleted since is removed by its delete event
+		if event.FullDocument.Type != bson.TypeEmbeddedDocument {
+			return nil
+		}
+		doc, _, err := transform.document(event.FullDocument.Document())
+		if err != nil {
+			return err
+		}
+		_, err = tc.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+		return err
+	case "delete":
+		_, err := tc.DeleteOne(ctx, filter)
+		return err
+	case "drop", "rename", "dropDatabase", "invalidate":
+		return fmt.Errorf("source collection had a %s, the sync has to be started again", event.OperationType)
+	default:
+		return nil
+	}
+}
+
+// Encode a change stream resume token as canonical extended JSON
+func encodeResumeToken(token bson.Raw) (string, error) {
+	if len(token) == 0 {
+		return "", errors.New("source server did not return a change stream resume token")
+	}
+
+	out, err := bson.MarshalExtJSON(token, true, false)
+	return string(out), err
+}
+
+// Decode a resume token encoded by encodeResumeToken
+func decodeResumeToken(s string) (bson.Raw, error) {
+	var token bson.Raw
+	err := bson.UnmarshalExtJSON([]byte(s), true, &token)
+	return token, err
+}
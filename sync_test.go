@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidSync(t *testing.T) {
+	if err := (copySpec{mode: modeUpsert}).validSync(); err != nil {
+		t.Errorf("expected sync matching on _id to be valid, got %v", err)
+	}
+	if err := (copySpec{mode: modeUpsert, key: "orderId"}).validSync(); err == nil {
+		t.Error("expected sync matching on another key to be refused")
+	}
+	if err := (copySpec{query: copyQuery{filter: `{"tenant":"acme"}`}}).validSync(); err == nil {
+		t.Error("expected sync of a filtered copy to be refused")
+	}
+}
+
+func TestResumeToken(t *testing.T) {
+	token, err := bson.Marshal(bson.D{{Key: "_data", Value: "8263A1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := encodeResumeToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeResumeToken(encoded)
+	if err != nil || !bytes.Equal(decoded, token) {
+		t.Errorf("expected resume token to round trip, got %s, %v", decoded, err)
+	}
+
+	if _, err := encodeResumeToken(nil); err == nil {
+		t.Error("expected error for a missing resume token")
+	}
+}
+
+func TestApplyChange_WithoutTarget(t *testing.T) {
+	key, _ := bson.Marshal(bson.D{{Key: "_id", Value: 1}})
+
+	// Neither change touches the target collection
+	deleted := changeEvent{OperationType: "update", DocumentKey: key, FullDocument: bson.RawValue{Type: bson.TypeNull}}
+	if err := applyChange(context.Background(), nil, deleted, parsedTransform{}); err != nil {
+		t.Errorf("expected update of a deleted document to be skipped, got %v", err)
+	}
+
+	dropped := changeEvent{OperationType: "drop", DocumentKey: key}
+	if err := applyChange(context.Background(), nil, dropped, parsedTransform{}); err == nil || !strings.Contains(err.Error(), "drop") {
+		t.Errorf("expected dropped source to stop the sync, got %v", err)
+	}
+}
+
+func TestResumable_Sync(t *testing.T) {
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage("mongodb://target:27017", "mongodb://source:27017").withState(ss)
+	spec := copySpec{sourceDatabase: "shop", sourceCollection: "orders", targetDatabase: "shop", targetCollection: "orders"}
+	if err := ss.setSyncCheckpoint(s.copyKey(spec), syncCheckpoint{Token: `{"_data":"8263"}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.resumable(spec) {
+		t.Error("expected copy without sync to ignore sync checkpoints")
+	}
+	spec.sync = true
+	if !s.resumable(spec) {
+		t.Error("expected sync to resume from its token")
+	}
+}
+
+func TestSync_ReplicaSet(t *testing.T) {
+	client, uri := replicaSetClient(t)
+	db := client.Database("mongo_move_txn")
+	seedCollection(t, db.Collection("source"), 20, "n")
+	db.Collection("target").Drop(context.Background())
+
+	ss, err := loadStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStorage(uri, uri).withState(ss)
+
+	syncing := make(chan struct{}, 1)
+	spec := copySpec{sourceDatabase: "mongo_move_txn", sourceCollection: "source", targetDatabase: "mongo_move_txn", targetCollection: "target", sync: true}
+	spec.progress = func(p copyProgress) {
+		if p.syncing {
+			select {
+			case syncing <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.sync(ctx, spec, func(initial func() error) error { return initial() })
+		done <- err
+	}()
+	select {
+	case <-syncing:
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync did not finish its initial copy")
+	}
+
+	source := db.Collection("source")
+	source.InsertOne(context.Background(), bson.D{{Key: "n", Value: 100}})
+	source.UpdateOne(context.Background(), bson.D{{Key: "n", Value: 0}}, bson.D{{Key: "$set", Value: bson.D{{Key: "updated", Value: true}}}})
+	source.DeleteOne(context.Background(), bson.D{{Key: "n", Value: 1}})
+
+	target := db.Collection("target")
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		count, _ := target.CountDocuments(context.Background(), bson.D{})
+		updated, _ := target.CountDocuments(context.Background(), bson.D{{Key: "updated", Value: true}})
+		if count == 20 && updated == 1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("expected changes to reach the target, got %d documents, %d updated", count, updated)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected cancelled sync to stop without error, got %v", err)
+	}
+	if sc, ok := ss.syncCheckpoint(s.copyKey(spec)); !ok || !sc.Copied || sc.Token == "" {
+		t.Errorf("expected resume token to be saved, got %+v", sc)
+	}
+}
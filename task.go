@@ -0,0 +1,90 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/spinner"
+)
+
+// State of a collection copy task
+type taskState int
+
+const (
+	taskPending   taskState = iota // Waiting for the copy to be started
+	taskRunning                    // Copy in progress
+	taskSucceeded                  // All documents copied
+	taskFailed                     // Copy stopped with an error
+	taskCancelled                  // Copy stopped by the user
+	taskPaused                     // Copy held between bulk writes until resumed
+)
+
+func (s taskState) String() string {
+	switch s {
+	case taskPending:
+		return "Not Started"
+	case taskRunning:
+		return "Copying"
+	case taskSucceeded:
+		return "Done"
+	case taskFailed:
+		return "Failed"
+	case taskCancelled:
+		return "Cancelled"
+	case taskPaused:
+		return "Paused"
+	default:
+		return "Unknown"
+	}
+}
+
+// Check if the task has a copy in progress, running or paused
+func (s taskState) active() bool {
+	return s == taskRunning || s == taskPaused
+}
+
+// Check if the task has stopped, successfully or not
+func (s taskState) finished() bool {
+	return s == taskSucceeded || s == taskFailed || s == taskCancelled
+}
+
+// Check if a task can move from this state to the next one.
+// Failed and cancelled tasks can be started again to retry them.
+func (s taskState) canMoveTo(next taskState) bool {
+	switch s {
+	case taskPending:
+		return next == taskRunning || next == taskCancelled
+	case taskRunning:
+		return next == taskPaused || next.finished()
+	case taskPaused:
+		return next == taskRunning || next.finished()
+	case taskFailed, taskCancelled:
+		return next == taskPending || next == taskRunning
+	default:
+		return false
+	}
+}
+
+// Move the task to the next state, recording the error it failed with.
+// Returns false and leaves the task unchanged if the move is not allowed.
+func (t *collectionCopyTask) moveTo(next taskState, err error) bool {
+	if !t.state.canMoveTo(next) {
+		return false
+	}
+
+	t.state = next
+	t.err = err
+	return true
+}
+
+// Field matching source and target documents, defaulting to _id
+func (t collectionCopyTask) writeKey() string {
+	if t.key == "" {
+		return defaultWriteKey
+	}
+
+	return t.key
+}
+
+// Initialize new copy task between source and target collections with its own spinner
+func newCollectionCopyTask(source collection, target collection) collectionCopyTask {
+	sp := spinner.New(spinner.WithSpinner(spinner.Line))
+	return collectionCopyTask{id: sp.ID(), source: source, target: target, spinner: sp}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTaskState_CanMoveTo(t *testing.T) {
+	tests := []struct {
+		from, to taskState
+		want     bool
+	}{
+		{taskPending, taskRunning, true},
+		{taskPending, taskSucceeded, false},
+		{taskRunning, taskSucceeded, true},
+		{taskRunning, taskFailed, true},
+		{taskRunning, taskCancelled, true},
+		{taskRunning, taskPending, false},
+		{taskSucceeded, taskRunning, false},
+		{taskFailed, taskRunning, true},
+		{taskCancelled, taskPending, true},
+		{taskRunning, taskPaused, true},
+		{taskPaused, taskRunning, true},
+		{taskPaused, taskCancelled, true},
+		{taskPending, taskPaused, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.canMoveTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestTaskState_Finished(t *testing.T) {
+	if taskPending.finished() || taskRunning.finished() {
+		t.Error("pending and running tasks should not be finished")
+	}
+	if !taskSucceeded.finished() || !taskFailed.finished() || !taskCancelled.finished() {
+		t.Error("succeeded, failed and cancelled tasks should be finished")
+	}
+}
+
+func TestCollectionCopyTask_MoveTo(t *testing.T) {
+	task := collectionCopyTask{}
+	if !task.moveTo(taskRunning, nil) || task.state != taskRunning {
+		t.Fatalf("expected task to be running, got %s", task.state)
+	}
+
+	err := errors.New("connection reset")
+	if !task.moveTo(taskFailed, err) || task.err != err {
+		t.Fatalf("expected task to fail with %v, got %s %v", err, task.state, task.err)
+	}
+
+	if task.moveTo(taskSucceeded, nil) {
+		t.Error("expected failed task not to move straight to succeeded")
+	}
+	if task.state != taskFailed {
+		t.Errorf("expected task to stay failed, got %s", task.state)
+	}
+}
+
+func TestIsCopyTasksComplete(t *testing.T) {
+	m := model{}
+	m.collectionChoices.copyTasks = []collectionCopyTask{
+		{id: 1, state: taskSucceeded},
+		{id: 2, state: taskRunning},
+	}
+	if m.IsCopyTasksComplete() {
+		t.Error("expected copy to be incomplete while a task is running")
+	}
+
+	m.collectionChoices.copyTasks[1].state = taskFailed
+	if !m.IsCopyTasksComplete() {
+		t.Error("expected copy to be complete once every task has finished")
+	}
+	if !m.hasFailedTasks() {
+		t.Error("expected failed task to be reported")
+	}
+}
+
+func TestCancelCopyTask(t *testing.T) {
+	cancelled := false
+	m := model{}
+	m.collectionChoices.copyTasks = []collectionCopyTask{
+		{id: 1, state: taskPaused, cancel: func() { cancelled = true }},
+		{id: 2, state: taskSucceeded},
+	}
+
+	if !m.cancelCopyTask(0) || !cancelled || m.collectionChoices.copyTasks[0].state != taskCancelled {
+		t.Errorf("expected paused task to be cancelled, got %s", m.collectionChoices.copyTasks[0].state)
+	}
+	if m.cancelCopyTask(1) {
+		t.Error("expected finished task not to be cancelled")
+	}
+	if !m.hasCancelledTasks() {
+		t.Error("expected cancelled task to be reported")
+	}
+}
+
+func TestTogglePauseCopyTask(t *testing.T) {
+	m := model{}
+	m.collectionChoices.copyTasks = []collectionCopyTask{
+		newCollectionCopyTask(collection{name: "orders"}, collection{name: "orders"}),
+	}
+	m.collectionChoices.copyTasks[0].state = taskRunning
+	m.collectionChoices.copyTasks[0].pause = newPauser()
+
+	m.togglePauseCopyTask(0)
+	task := m.collectionChoices.copyTasks[0]
+	if task.state != taskPaused || !task.pause.paused() {
+		t.Fatalf("expected task to be paused, got %s", task.state)
+	}
+
+	if cmd := m.togglePauseCopyTask(0); cmd == nil {
+		t.Error("expected continued task to spin its spinner again")
+	}
+	task = m.collectionChoices.copyTasks[0]
+	if task.state != taskRunning || task.pause.paused() {
+		t.Errorf("expected task to be running, got %s", task.state)
+	}
+}
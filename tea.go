@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -19,7 +22,14 @@ const (
 	taskMapColumnName           = "Collections Map"
 	recordsCountColumnName      = "Records"
 	CopyStatusColumnName        = "Copy Status"
+	writeModeColumnName         = "Write Mode"
+	queryColumnName             = "Documents"
+	indexesColumnName           = "Indexes"
+	progressColumnName          = "Progress"
+	verifyColumnName            = "Verify"
+	copyTaskIdKey               = "id"
 	progressBarWidth            = 71
+	shutdownTimeout             = 5 * time.Second
 	dotChar                     = " • "
 	banner                      = `
   __  __                           __  __                
@@ -55,6 +65,7 @@ const (
 // General stuff for styling the view
 var (
 	green         = lipgloss.NewStyle().Foreground(lipgloss.Color("#54ad48"))
+	red           = lipgloss.NewStyle().Foreground(lipgloss.Color("#e0443e"))
 	keywordStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("211"))
 	subtleStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
 	checkboxStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
@@ -62,8 +73,21 @@ var (
 )
 
 type collection struct {
-	name  string
-	count int64
+	name   string
+	count  int64
+	create bool // Target collection that does not exist yet and is created with the source options
+	view   bool // Source collection that is a view on another collection
+}
+
+func (c collection) String() string {
+	if c.create {
+		return c.name + " (new)"
+	}
+	if c.view {
+		return c.name + " (view)"
+	}
+
+	return c.name
 }
 
 type collections struct {
@@ -82,11 +106,40 @@ type TableData struct {
 }
 
 type collectionCopyTask struct {
-	id       int
-	target   collection
-	source   collection
-	spinner  spinner.Model
-	complete bool
+	id      int
+	target  collection
+	source  collection
+	spinner spinner.Model
+	state   taskState // Where the task is in its lifecycle
+	err     error     // Error the last copy attempt failed with
+	mode    writeMode // How documents are written to the target collection
+	key     string    // Field matching source and target documents in upsert and insert-missing modes
+	query   copyQuery // Subset of source documents to copy
+	resume  bool      // Continue from the saved checkpoint instead of starting again
+	indexes indexCopy // When source indexes are recreated on the target, if at all
+	staging bool      // Load a staging collection that replaces the target in one rename, replace mode only
+
+	transaction bool              // Delete and write the target documents in one transaction where the target supports it
+	notice      string            // How the last copy differed from what was asked for
+	sync        bool              // Keep applying source changes to the target after the copy until cancelled
+	watermark   string            // Increasing field, only documents above the last copy's highest value are copied
+	transform   transformPipeline // Steps applied to every source document before it is written, set by plans
+
+	indexReport *indexReport // Indexes copied by the last copy, nil when indexes were not copied
+
+	verifying    bool          // Target is being compared with the source
+	verifyReport *verifyReport // Result of the last verify, nil until the copy has been verified
+
+	previewing   bool          // Dry run of the task is in progress
+	dryRunReport *dryRunReport // What the task would do, nil until previewed or once the task is changed
+
+	progress copyProgress  // Documents copied so far by the running or last copy
+	started  time.Time     // When the running or last copy started writing documents
+	elapsed  time.Duration // Time between the start and the latest progress update
+
+	attempt int                // Number of times the task has been started, so messages from earlier copies are ignored
+	cancel  context.CancelFunc // Stops the running copy
+	pause   *pauser            // Holds the running copy between bulk writes
 }
 
 type (
@@ -99,9 +152,39 @@ type (
 
 type copyMsg struct {
 	collectionId int
+	attempt      int
+	report       copyReport
 	error        error
 }
 
+// Result of verifying a finished copy
+type verifyMsg struct {
+	collectionId int
+	attempt      int
+	report       verifyReport
+}
+
+// Result of a dry run of a copy task
+type dryRunMsg struct {
+	collectionId int
+	report       dryRunReport
+}
+
+// Source document of a copy task next to its transformed document
+type transformPreviewMsg struct {
+	collectionId int
+	preview      transformPreview
+	err          error
+}
+
+// Progress of a running copy, sent each time documents are written
+type copyProgressMsg struct {
+	collectionId int
+	attempt      int
+	progress     copyProgress
+	updates      <-chan copyProgress // Channel the next progress update is read from
+}
+
 type errMsg struct {
 	err     error
 	context string
@@ -129,7 +212,17 @@ type collectionChoicesViewModel struct {
 	collectionsCopied   bool
 	debounce            time.Duration // debounce duraiton for loading spinner
 	altscreen           bool
-	completeCount       int
+	message             string               // Shown under the title, such as where a plan was saved
+	dryRun              bool                 // Enter previews what the copy tasks would do instead of copying
+	backup              backupMode           // Where target documents are saved before the copy tasks delete them
+	preview             *transformPreviewMsg // Transformed document shown under the copy tasks, nil when hidden
+}
+
+// Is a filter of one of the tables being typed
+func (vm collectionChoicesViewModel) filterInputFocused() bool {
+	return vm.sourceTable.GetIsFilterInputFocused() ||
+		vm.targetTable.GetIsFilterInputFocused() ||
+		vm.copyTaskTable.GetIsFilterInputFocused()
 }
 
 type databaseChoicesViewModel struct {
@@ -149,17 +242,24 @@ type databaseChoicesViewModel struct {
 	targetPageSize          int          // Default size of a page of all tables
 	targetTable             table.Model  // Table that displays collections in the target database
 	targetTableFiltered     bool
-	debounce                time.Duration // debounce duraiton for loading spinner
+	debounce                time.Duration       // debounce duraiton for loading spinner
+	wholeDatabase           *collectionPatterns // Collections copied to the same names once loaded, nil when choosing them by hand
+	message                 string              // Shown under the title, such as an invalid pattern
 }
 
 // Main model
 type model struct {
 	keyBindings       keyModel
 	storage           storage                    // Storage
+	profileChoices    profileChoicesViewModel    // Model for profileChoicesView view
 	fatalError        *fatalError                // Fatal Error details
 	databaseChoices   databaseChoicesViewModel   // Model for databaseChoicesView view
 	collectionChoices collectionChoicesViewModel // Model for collectionChoicesTable view
 	spinner           spinner.Model              // Database and collection loading spinner
+	scheduler         *scheduler                 // Limits how many copy tasks run at once
+	prompt            prompt                     // Text input open over the current view
+	plan              *plan                      // Plan loaded at startup, cleared once applied to the copy tasks
+	protection        protection                 // Targets copies only write to once confirmed
 }
 
 // Init function that returns an initial command for the application to run
@@ -167,7 +267,12 @@ func (m model) Init() tea.Cmd {
 
 	var cmds []tea.Cmd
 	cmds = append(cmds, m.spinner.Tick)
-	cmds = append(cmds, m.getSourceDatabases)
+	if m.plan != nil {
+		// Databases come from the plan so go straight to loading collections
+		cmds = append(cmds, m.getCollections)
+	} else if m.profileChoices.profilesChosen {
+		cmds = append(cmds, m.getSourceDatabases)
+	}
 
 	return tea.Batch(cmds...)
 }
@@ -179,12 +284,12 @@ func (m model) getSourceDatabases() tea.Msg {
 	var databases databases
 	var err error
 
-	databases.source, err = m.storage.getSourceDatabases()
+	databases.source, err = m.storage.getSourceDatabases(context.Background())
 	if err != nil {
 		return errMsg{err, "getting source databases"}
 	}
 
-	databases.target, err = m.storage.getTargetDatabases()
+	databases.target, err = m.storage.getTargetDatabases(context.Background())
 	if err != nil {
 		return errMsg{err, "getting target databases"}
 	}
@@ -196,12 +301,12 @@ func (m model) getCollections() tea.Msg {
 	var collections collections
 	var err error
 
-	collections.target, err = m.storage.getTargetCollections(m.databaseChoices.targetDatabaseChoice)
+	collections.target, err = m.storage.getTargetCollections(context.Background(), m.databaseChoices.targetDatabaseChoice)
 	if err != nil {
 		return errMsg{err, "getting target collections"}
 	}
 
-	collections.source, err = m.storage.getSourceCollections(m.databaseChoices.sourceDatabaseChoice)
+	collections.source, err = m.storage.getSourceCollections(context.Background(), m.databaseChoices.sourceDatabaseChoice)
 	if err != nil {
 		return errMsg{err, "getting source collections"}
 	}
@@ -209,26 +314,223 @@ func (m model) getCollections() tea.Msg {
 	return getCollectionsMsg(collections)
 }
 
-func (m model) copyData() []tea.Cmd {
-	var err error
+// Start copying the pending tasks, focusing the copy task table
+func (m *model) startCopy() tea.Cmd {
+	m.collectionChoices.sourceTable = m.collectionChoices.sourceTable.Focused(false)
+	m.collectionChoices.targetTable = m.collectionChoices.targetTable.Focused(false)
+	m.collectionChoices.copyTaskTable = m.collectionChoices.copyTaskTable.Focused(true)
+	m.collectionChoices.CopyStarted = true
+
+	// Create copy task for each collection map
+	var cmds = m.copyData()
+	m.buildCollectionMapRows()
+
+	return tea.Batch(cmds...)
+}
+
+// Start a copy for every task that has not been started yet
+func (m *model) copyData() []tea.Cmd {
 	var cmds []tea.Cmd
 
-	for _, c := range m.collectionChoices.copyTasks {
-		cmd := func() tea.Msg {
-			err = m.storage.copy(c.source.name, c.target.name, m.databaseChoices.sourceDatabaseChoice, m.databaseChoices.targetDatabaseChoice)
-			if err != nil {
-				return copyMsg{collectionId: c.id, error: err}
-			}
+	for i := range m.collectionChoices.copyTasks {
+		if m.collectionChoices.copyTasks[i].state == taskPending {
+			cmds = append(cmds, m.startCopyTask(i))
+		}
+	}
 
-			return copyMsg{collectionId: c.id, error: nil}
+	return cmds
+}
+
+// Mark the task at index i as running and return the commands that spin its spinner and copy its data
+func (m *model) startCopyTask(i int) tea.Cmd {
+	if !m.collectionChoices.copyTasks[i].moveTo(taskRunning, nil) {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &m.collectionChoices.copyTasks[i]
+	task.progress = copyProgress{}
+	task.started = time.Time{}
+	task.attempt++
+	task.indexReport = nil
+	task.verifyReport = nil
+	task.notice = ""
+	task.cancel = cancel
+	task.pause = newPauser()
+	c := *task
+
+	// Progress updates are dropped while the previous one has not been read yet,
+	// later updates include the documents copied since
+	updates := make(chan copyProgress, 1)
+	spec := m.copySpec(c)
+	spec.pause = c.pause
+	spec.progress = func(p copyProgress) {
+		select {
+		case updates <- p:
+		default:
+		}
+	}
+
+	cmd := func() tea.Msg {
+		defer close(updates)
+		defer cancel()
+
+		// Wait for a free slot so only a limited number of collections are copied at once
+		var report copyReport
+		var err error
+		if spec.sync {
+			// Only the initial copy takes a slot, the sync then runs until it is cancelled
+			report, err = m.storage.sync(ctx, spec, func(initial func() error) error {
+				return m.scheduler.run(ctx, initial)
+			})
+		} else {
+			err = m.scheduler.run(ctx, func() error {
+				var err error
+				report, err = m.storage.copy(ctx, spec)
+				return err
+			})
+		}
+		if err != nil {
+			return copyMsg{collectionId: c.id, attempt: c.attempt, report: report, error: err}
+		}
+
+		return copyMsg{collectionId: c.id, attempt: c.attempt, report: report, error: nil}
+	}
+
+	return tea.Batch(c.spinner.Tick, cmd, waitForProgress(c.id, c.attempt, updates))
+}
+
+// Wait for the next progress update of a copy, returning nothing once the copy has finished
+func waitForProgress(collectionId int, attempt int, updates <-chan copyProgress) tea.Cmd {
+	return func() tea.Msg {
+		p, ok := <-updates
+		if !ok {
+			return nil
+		}
+
+		return copyProgressMsg{collectionId: collectionId, attempt: attempt, progress: p, updates: updates}
+	}
+}
+
+// Stop the copy of the task at index i, returning false if it has no copy in progress
+func (m *model) cancelCopyTask(i int) bool {
+	task := &m.collectionChoices.copyTasks[i]
+	if !task.state.active() {
+		return false
+	}
+
+	task.cancel()
+	return task.moveTo(taskCancelled, nil)
+}
+
+// Pause the copy of the task at index i, or let it continue if it is paused.
+// Returns the command that spins its spinner again once it continues.
+func (m *model) togglePauseCopyTask(i int) tea.Cmd {
+	task := &m.collectionChoices.copyTasks[i]
+	switch task.state {
+	case taskRunning:
+		task.pause.pause()
+		task.moveTo(taskPaused, nil)
+	case taskPaused:
+		task.pause.resume()
+		task.moveTo(taskRunning, nil)
+		return task.spinner.Tick
+	}
+
+	return nil
+}
+
+// Compare the target of the succeeded task at index i with its source, returning nil if it can't be verified
+func (m *model) startVerifyTask(i int) tea.Cmd {
+	task := &m.collectionChoices.copyTasks[i]
+	if task.state != taskSucceeded || task.verifying {
+		return nil
+	}
+	task.verifying = true
+	task.verifyReport = nil
+	c := *task
+	spec := m.copySpec(c)
+
+	return func() tea.Msg {
+		report := m.storage.verifyResult(context.Background(), spec)
+		return verifyMsg{collectionId: c.id, attempt: c.attempt, report: *report}
+	}
+}
+
+// Preview what every pending task would do without writing anything, limited by the scheduler like copies
+func (m *model) dryRunCopyTasks() []tea.Cmd {
+	var cmds []tea.Cmd
+	for i := range m.collectionChoices.copyTasks {
+		task := &m.collectionChoices.copyTasks[i]
+		if task.state != taskPending || task.previewing {
+			continue
 		}
+		task.previewing = true
+		task.dryRunReport = nil
+
+		id := task.id
+		spec := m.copySpec(*task)
+		cmds = append(cmds, func() tea.Msg {
+			var report *dryRunReport
+			err := m.scheduler.run(context.Background(), func() error {
+				report = m.storage.dryRunResult(context.Background(), spec)
+				return nil
+			})
+			if err != nil {
+				return dryRunMsg{collectionId: id, report: dryRunReport{Error: err.Error()}}
+			}
 
-		cmds = append(cmds, cmd)
+			return dryRunMsg{collectionId: id, report: *report}
+		})
 	}
 
 	return cmds
 }
 
+// Read one source document of a task and apply its transformation, without writing anything
+func (m model) previewTransform(i int) tea.Cmd {
+	id := m.collectionChoices.copyTasks[i].id
+	spec := m.copySpec(m.collectionChoices.copyTasks[i])
+
+	return func() tea.Msg {
+		preview, err := m.storage.previewTransform(context.Background(), spec)
+		return transformPreviewMsg{collectionId: id, preview: preview, err: err}
+	}
+}
+
+// Save the verify reports of every verified task to the report file
+func (m model) saveVerifyReports() error {
+	var reports []verifyReport
+	for _, t := range m.collectionChoices.copyTasks {
+		if t.verifyReport != nil {
+			reports = append(reports, *t.verifyReport)
+		}
+	}
+
+	return m.storage.saveVerifyReports(reports)
+}
+
+// Details of the copy to run for a task
+func (m model) copySpec(c collectionCopyTask) copySpec {
+	return copySpec{
+		sourceDatabase:   m.databaseChoices.sourceDatabaseChoice,
+		sourceCollection: c.source.name,
+		targetDatabase:   m.databaseChoices.targetDatabaseChoice,
+		targetCollection: c.target.name,
+		mode:             c.mode,
+		key:              c.key,
+		query:            c.query,
+		resume:           c.resume,
+		indexes:          c.indexes,
+		create:           c.target.create,
+		backup:           m.collectionChoices.backup,
+		staging:          c.staging,
+		transaction:      c.transaction,
+		sync:             c.sync,
+		watermark:        c.watermark,
+		transform:        c.transform,
+	}
+}
+
 // Updates - Functions that handle incoming events and updates the model accordingly
 // https://github.com/charmbracelet/bubbletea#the-update-method
 
@@ -260,12 +562,36 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case getCollectionsMsg:
 		m.databaseChoices.sourceCollections = msg.source
 		m.databaseChoices.targetCollections = msg.target
-		m.buildCollectionTableRows()
 
 		// Debounce spinner
-		return m, tea.Tick(time.Duration(m.databaseChoices.debounce), func(_ time.Time) tea.Msg {
+		var cmds []tea.Cmd
+		cmds = append(cmds, tea.Tick(time.Duration(m.databaseChoices.debounce), func(_ time.Time) tea.Msg {
 			return collectionsLoadedMsg(true)
-		})
+		}))
+
+		// Fill the copy task table from the plan loaded at startup or the whole-database copy and go straight to it
+		if m.plan != nil || m.databaseChoices.wholeDatabase != nil {
+			if m.plan != nil {
+				if err := m.applyPlan(); err != nil {
+					m.collectionChoices.message = red.Render(err.Error())
+				}
+			} else if conflicts := m.applyDatabaseCopy(); len(conflicts) > 0 {
+				m.collectionChoices.message = red.Render(fmt.Sprintf("Views left to pair by hand, their names are taken in the target: %s", strings.Join(conflicts, ", ")))
+			} else if len(m.collectionChoices.copyTasks) == 0 {
+				m.collectionChoices.message = red.Render("No source collections match the patterns")
+			}
+			if len(m.collectionChoices.copyTasks) > 0 {
+				m.collectionChoices.sourceTable = m.collectionChoices.sourceTable.Focused(false)
+				m.collectionChoices.targetTable = m.collectionChoices.targetTable.Focused(false)
+				m.collectionChoices.copyTaskTable = m.collectionChoices.copyTaskTable.Focused(true)
+				m.collectionChoices.altscreen = true
+				m.buildCollectionMapRows()
+				cmds = append(cmds, tea.EnterAltScreen)
+			}
+		}
+		m.buildCollectionTableRows()
+
+		return m, tea.Batch(cmds...)
 	case collectionsLoadedMsg:
 		m.collectionChoices.collectionsLoaded = true
 		return m, tea.ClearScreen
@@ -274,14 +600,66 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Tick(time.Duration(m.collectionChoices.debounce), func(_ time.Time) tea.Msg {
 			return copyCompleteMsg(msg)
 		})
+	case copyProgressMsg:
+		for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
+			task := &m.collectionChoices.copyTasks[i]
+			if msg.collectionId == task.id && msg.attempt == task.attempt && task.state.active() {
+				// Time starts from the first update, sent once the copy has a free slot
+				if task.started.IsZero() {
+					task.started = time.Now()
+				}
+				task.progress = msg.progress
+				task.elapsed = time.Since(task.started)
+			}
+		}
+		m.buildCollectionMapRows()
+
+		return m, waitForProgress(msg.collectionId, msg.attempt, msg.updates)
 	case copyCompleteMsg:
 		for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
-			if msg.collectionId == m.collectionChoices.copyTasks[i].id {
-				m.collectionChoices.copyTasks[i].complete = true
-				m.collectionChoices.collectionsCopied = m.IsCopyTasksComplete()
+			if msg.collectionId == m.collectionChoices.copyTasks[i].id && msg.attempt == m.collectionChoices.copyTasks[i].attempt {
+				m.collectionChoices.copyTasks[i].indexReport = msg.report.indexes
+				m.collectionChoices.copyTasks[i].notice = msg.report.notice
+				if errors.Is(msg.error, context.Canceled) {
+					m.collectionChoices.copyTasks[i].moveTo(taskCancelled, nil)
+				} else if msg.error != nil {
+					m.collectionChoices.copyTasks[i].moveTo(taskFailed, msg.error)
+				} else {
+					m.collectionChoices.copyTasks[i].progress.copied = m.collectionChoices.copyTasks[i].progress.total
+					m.collectionChoices.copyTasks[i].moveTo(taskSucceeded, nil)
+				}
 			}
-			m.buildCollectionMapRows()
 		}
+		m.collectionChoices.collectionsCopied = m.IsCopyTasksComplete()
+		m.buildCollectionMapRows()
+
+	case transformPreviewMsg:
+		m.collectionChoices.preview = &msg
+
+	case dryRunMsg:
+		for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
+			if msg.collectionId == m.collectionChoices.copyTasks[i].id && m.collectionChoices.copyTasks[i].previewing {
+				report := msg.report
+				m.collectionChoices.copyTasks[i].previewing = false
+				m.collectionChoices.copyTasks[i].dryRunReport = &report
+			}
+		}
+		m.buildCollectionMapRows()
+
+	case verifyMsg:
+		for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
+			if msg.collectionId == m.collectionChoices.copyTasks[i].id && msg.attempt == m.collectionChoices.copyTasks[i].attempt {
+				report := msg.report
+				m.collectionChoices.copyTasks[i].verifying = false
+				m.collectionChoices.copyTasks[i].verifyReport = &report
+			}
+		}
+		if err := m.saveVerifyReports(); err != nil {
+			m.collectionChoices.message = red.Render(fmt.Sprintf("Could not save verify reports: %s", err))
+		} else {
+			m.collectionChoices.message = green.Render(fmt.Sprintf("Verify reports saved to %s", m.storage.verify.reportFile))
+		}
+		m.buildCollectionMapRows()
 
 	case spinner.TickMsg:
 		var (
@@ -298,9 +676,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.spinner, cmd = m.spinner.Update(msg)
 			cmds = append(cmds, cmd)
 		} else if m.collectionChoices.CopyStarted {
-			// copy started spinners
+			// copy started spinners, which stop once their task has finished
 			for i := range m.collectionChoices.copyTasks {
-				if m.collectionChoices.copyTasks[i].id == msg.ID {
+				if m.collectionChoices.copyTasks[i].id == msg.ID && m.collectionChoices.copyTasks[i].state == taskRunning {
 					m.collectionChoices.copyTasks[i].spinner, cmd = m.collectionChoices.copyTasks[i].spinner.Update(msg)
 					cmds = append(cmds, cmd)
 				}
@@ -316,6 +694,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Hand off the message and model to the appropriate update function for the
 	// appropriate view based on the current state.
+	if m.prompt.active() {
+		return updatePrompt(msg, m)
+	}
+	if !m.profileChoices.profilesChosen {
+		return updateProfileChoices(msg, m)
+	}
 	if !(m.databaseChoices.databasesChosen) {
 		return updateDatabaseChoices(msg, m)
 	}
@@ -368,6 +752,18 @@ func updateDatabaseChoices(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 
 				return m, m.getCollections
 			}
+		case key.Matches(msg, m.keyBindings.keys.CopyDatabase):
+			// Copy every matching source collection to the same name in the highlighted target database
+			if m.databaseChoices.targetTable.GetFocused() {
+				row := m.databaseChoices.targetTable.HighlightedRow()
+				m.databaseChoices.targetDatabaseChoice = row.Data[targetDatabasesColumnName].(string)
+
+				var cmd tea.Cmd
+				m.prompt, cmd = newPrompt(promptCopyDatabase, 0,
+					fmt.Sprintf("Collections of %s copied to %s, such as \"orders*, !*_tmp\" (empty copies all)",
+						m.databaseChoices.sourceDatabaseChoice, m.databaseChoices.targetDatabaseChoice), "")
+				return m, cmd
+			}
 		}
 
 	}
@@ -420,30 +816,240 @@ func updateCollectionChoiceTable(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
+		case m.collectionChoices.filterInputFocused() && !key.Matches(msg, m.keyBindings.keys.FilterQuit):
+			// Keys typed into a table filter go only to the table, task bindings are letters too
 		case key.Matches(msg, m.keyBindings.keys.Enter):
+			// Preview the copy tasks instead of copying while dry run is on
+			if m.collectionChoices.dryRun && m.collectionChoices.altscreen && !m.collectionChoices.CopyStarted {
+				cmds := m.dryRunCopyTasks()
+				m.buildCollectionMapRows()
+
+				return m, tea.Batch(cmds...)
+			}
 			if len(m.collectionChoices.copyTasks) != 0 &&
 				m.collectionChoices.altscreen &&
 				!m.collectionChoices.collectionsCopied {
-				m.collectionChoices.sourceTable = m.collectionChoices.sourceTable.Focused(false)
-				m.collectionChoices.targetTable = m.collectionChoices.targetTable.Focused(false)
-				m.collectionChoices.copyTaskTable = m.collectionChoices.copyTaskTable.Focused(true)
-				m.collectionChoices.CopyStarted = true
+				// Ask for the target database name before deleting documents or writing to a protected target
+				if title := m.copyConfirmation(); title != "" {
+					var cmd tea.Cmd
+					m.prompt, cmd = newPrompt(promptConfirmCopy, 0, title, "")
+					return m, cmd
+				}
 
-				var cmds []tea.Cmd
-				// Set the spinner for each task to spins
-				for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
-					cmd := func() tea.Msg {
-						return m.collectionChoices.copyTasks[i].spinner.Tick()
+				return m, m.startCopy()
+			}
+		case key.Matches(msg, m.keyBindings.keys.DryRun):
+			// Switch enter between copying and previewing the copy tasks, previews are cleared when switched off
+			if m.collectionChoices.altscreen && !m.collectionChoices.CopyStarted {
+				m.collectionChoices.dryRun = !m.collectionChoices.dryRun
+				if !m.collectionChoices.dryRun {
+					for i := range m.collectionChoices.copyTasks {
+						m.collectionChoices.copyTasks[i].dryRunReport = nil
+					}
+				}
+				m.buildCollectionMapRows()
+			}
+		case key.Matches(msg, m.keyBindings.keys.ToggleBackup):
+			// Switch where target documents are saved before copies delete them, for every task
+			if m.collectionChoices.altscreen && !m.collectionChoices.CopyStarted {
+				m.collectionChoices.backup = m.collectionChoices.backup.next()
+			}
+		case key.Matches(msg, m.keyBindings.keys.ToggleWriteMode):
+			// Switch the highlighted task to the next write mode
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending {
+					m.collectionChoices.copyTasks[i].mode = m.collectionChoices.copyTasks[i].mode.next()
+					m.collectionChoices.copyTasks[i].staging = m.collectionChoices.copyTasks[i].staging && m.collectionChoices.copyTasks[i].mode == modeReplace
+					if !m.collectionChoices.copyTasks[i].mode.keyed() {
+						m.collectionChoices.copyTasks[i].watermark = ""
+					}
+					m.collectionChoices.copyTasks[i].dryRunReport = nil
+					m.buildCollectionMapRows()
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.NewTarget):
+			// Copy the chosen source collection into a target collection that does not exist yet
+			if m.collectionChoices.targetTable.GetFocused() && m.collectionChoices.currentCopyTask.source.name != "" {
+				source := m.collectionChoices.currentCopyTask.source.name
+				var cmd tea.Cmd
+				m.prompt, cmd = newPrompt(promptNewTarget, 0,
+					fmt.Sprintf("Name of the new target collection for %s, created with its options", source), source)
+				return m, cmd
+			}
+		case key.Matches(msg, m.keyBindings.keys.AutoMap):
+			// Pair every source collection with the target collection of the same or renamed name
+			if m.collectionChoices.sourceTable.GetFocused() && !m.collectionChoices.altscreen {
+				var cmd tea.Cmd
+				m.prompt, cmd = newPrompt(promptAutoMap, 0,
+					"Rename rule from source to target names: prefix=<text>, suffix=<text> or s/<regexp>/<replacement>/ (empty matches same names)", "")
+				return m, cmd
+			}
+		case key.Matches(msg, m.keyBindings.keys.ToggleIndexes):
+			// Switch when the highlighted task copies indexes
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending {
+					m.collectionChoices.copyTasks[i].indexes = m.collectionChoices.copyTasks[i].indexes.next()
+					m.collectionChoices.copyTasks[i].dryRunReport = nil
+					m.buildCollectionMapRows()
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.ToggleStaging):
+			// Switch whether the highlighted replace task loads a staging collection first
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending && m.collectionChoices.copyTasks[i].mode == modeReplace {
+					m.collectionChoices.copyTasks[i].staging = !m.collectionChoices.copyTasks[i].staging
+					m.collectionChoices.copyTasks[i].transaction = false
+					m.collectionChoices.copyTasks[i].resume = false
+					m.collectionChoices.copyTasks[i].dryRunReport = nil
+					m.buildCollectionMapRows()
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.ToggleTxn):
+			// Switch whether the highlighted task writes the target in one transaction
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending {
+					m.collectionChoices.copyTasks[i].transaction = !m.collectionChoices.copyTasks[i].transaction
+					m.collectionChoices.copyTasks[i].staging = false
+					m.collectionChoices.copyTasks[i].resume = false
+					m.collectionChoices.copyTasks[i].dryRunReport = nil
+					m.buildCollectionMapRows()
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.ToggleSync):
+			// Switch whether the highlighted task keeps applying source changes after its copy
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending {
+					m.collectionChoices.copyTasks[i].sync = !m.collectionChoices.copyTasks[i].sync
+					if m.collectionChoices.copyTasks[i].sync {
+						m.collectionChoices.copyTasks[i].watermark = ""
 					}
+					m.collectionChoices.copyTasks[i].resume = false
+					m.collectionChoices.copyTasks[i].dryRunReport = nil
+					m.buildCollectionMapRows()
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.EditWatermark):
+			// Choose the increasing field the highlighted task copies newer documents of
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending {
+					task := m.collectionChoices.copyTasks[i]
+					var cmd tea.Cmd
+					m.prompt, cmd = newPrompt(promptWatermark, task.id,
+						fmt.Sprintf("Increasing field of %s, only documents above its highest value in the last copy are copied (empty for a full copy)", task.source.name),
+						task.watermark)
+					return m, cmd
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.PreviewTransform):
+			// Show one source document of the highlighted task as it would be written, or hide the preview
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if m.collectionChoices.preview != nil {
+					m.collectionChoices.preview = nil
+				} else if i := m.highlightedCopyTaskIndex(); i >= 0 {
+					return m, m.previewTransform(i)
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.EditWriteKey):
+			// Choose the field matching documents for the highlighted task
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending {
+					task := m.collectionChoices.copyTasks[i]
+					var cmd tea.Cmd
+					m.prompt, cmd = newPrompt(promptWriteKey, task.id,
+						fmt.Sprintf("Key field matching %s documents to %s documents", task.source.name, task.target.name),
+						task.writeKey())
+					return m, cmd
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.EditFilter):
+			// Choose which documents of the highlighted task are copied
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.collectionChoices.copyTasks[i].state == taskPending {
+					task := m.collectionChoices.copyTasks[i]
+					var cmd tea.Cmd
+					m.prompt, cmd = newPrompt(promptFilter, task.id,
+						fmt.Sprintf("Extended JSON filter for documents copied from %s, empty copies all", task.source.name),
+						task.query.filter)
+					return m, cmd
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.SavePlan):
+			// Save the copy tasks as a plan file
+			if m.collectionChoices.altscreen && len(m.collectionChoices.copyTasks) > 0 {
+				var cmd tea.Cmd
+				m.prompt, cmd = newPrompt(promptSavePlan, 0, "Save copy tasks as plan file", "plan.json")
+				return m, cmd
+			}
+		case key.Matches(msg, m.keyBindings.keys.Retry):
+			// Copy the highlighted task again if it failed or was cancelled
+			if m.collectionChoices.copyTaskTable.GetFocused() && m.collectionChoices.CopyStarted {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && (m.collectionChoices.copyTasks[i].state == taskFailed || m.collectionChoices.copyTasks[i].state == taskCancelled) {
+					m.collectionChoices.copyTasks[i].resume = false
+					cmd := m.startCopyTask(i)
+					m.collectionChoices.collectionsCopied = false
+					m.buildCollectionMapRows()
 
-					cmds = append(cmds, cmd)
+					return m, cmd
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.Resume):
+			// Continue the highlighted task from its checkpoint, or choose to when it is started
+			if m.collectionChoices.copyTaskTable.GetFocused() {
+				i := m.highlightedCopyTaskIndex()
+				if i < 0 || m.collectionChoices.copyTasks[i].transaction || !m.storage.resumable(m.copySpec(m.collectionChoices.copyTasks[i])) {
+					break
 				}
 
-				// Create copy task for each collection map
-				var c = m.copyData()
-				cmds = append(cmds, c...)
+				switch m.collectionChoices.copyTasks[i].state {
+				case taskPending:
+					m.collectionChoices.copyTasks[i].resume = !m.collectionChoices.copyTasks[i].resume
+					m.collectionChoices.copyTasks[i].dryRunReport = nil
+					m.buildCollectionMapRows()
+				case taskFailed, taskCancelled:
+					m.collectionChoices.copyTasks[i].resume = true
+					cmd := m.startCopyTask(i)
+					m.collectionChoices.collectionsCopied = false
+					m.buildCollectionMapRows()
 
-				return m, tea.Batch(cmds...)
+					return m, cmd
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.Verify):
+			// Compare the highlighted task's target with its source once it has been copied
+			if m.collectionChoices.copyTaskTable.GetFocused() && m.collectionChoices.CopyStarted {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 {
+					cmd := m.startVerifyTask(i)
+					m.buildCollectionMapRows()
+
+					return m, cmd
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.CancelTask):
+			// Stop the highlighted task's copy
+			if m.collectionChoices.copyTaskTable.GetFocused() && m.collectionChoices.CopyStarted {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 && m.cancelCopyTask(i) {
+					m.collectionChoices.collectionsCopied = m.IsCopyTasksComplete()
+					m.buildCollectionMapRows()
+				}
+			}
+		case key.Matches(msg, m.keyBindings.keys.CancelAll):
+			// Stop every copy in progress
+			if m.collectionChoices.copyTaskTable.GetFocused() && m.collectionChoices.CopyStarted {
+				for i := range m.collectionChoices.copyTasks {
+					m.cancelCopyTask(i)
+				}
+				m.collectionChoices.collectionsCopied = m.IsCopyTasksComplete()
+				m.buildCollectionMapRows()
+			}
+		case key.Matches(msg, m.keyBindings.keys.TogglePause):
+			// Hold the highlighted task's copy between bulk writes, or let it continue
+			if m.collectionChoices.copyTaskTable.GetFocused() && m.collectionChoices.CopyStarted {
+				if i := m.highlightedCopyTaskIndex(); i >= 0 {
+					cmd := m.togglePauseCopyTask(i)
+					m.buildCollectionMapRows()
+
+					return m, cmd
+				}
 			}
 		case key.Matches(msg, m.keyBindings.keys.Tab):
 			var cmd tea.Cmd
@@ -503,7 +1109,7 @@ func updateCollectionChoiceTable(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 					var name = row.Data[targetCollectionsColumnName].(string)
 					var count = row.Data[recordsCountColumnName].(int64)
 					col := collection{name: name, count: count}
-					m.collectionChoices.currentCopyTask.target = col
+					m.collectionChoices.currentCopyTask = newCollectionCopyTask(m.collectionChoices.currentCopyTask.source, col)
 					m.buildCollectionMapRows()
 
 					// Delete collection so it can't be selected again
@@ -511,9 +1117,6 @@ func updateCollectionChoiceTable(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 					m.databaseChoices.targetCollections = removeItem(m.databaseChoices.targetCollections, i)
 					m.buildCollectionTableRows()
 
-					// Set an individual spinner for each task
-					m.collectionChoices.currentCopyTask.spinner = spinner.New(spinner.WithSpinner(spinner.Line))
-					m.collectionChoices.currentCopyTask.id = m.collectionChoices.currentCopyTask.spinner.ID()
 					m.collectionChoices.copyTasks = append(m.collectionChoices.copyTasks, m.collectionChoices.currentCopyTask)
 					m.buildCollectionMapRows()
 
@@ -541,7 +1144,9 @@ func updateCollectionChoiceTable(msg tea.Msg, m model) (tea.Model, tea.Cmd) {
 						var target = row.Data[targetCollectionsColumnName].(collection)
 						var source = row.Data[sourceCollectionsColumnName].(collection)
 
-						m.databaseChoices.targetCollections = append(m.databaseChoices.targetCollections, target)
+						if !target.create {
+							m.databaseChoices.targetCollections = append(m.databaseChoices.targetCollections, target)
+						}
 						m.databaseChoices.sourceCollections = append(m.databaseChoices.sourceCollections, source)
 						m.collectionChoices.copyTasks = removeItem(m.collectionChoices.copyTasks, i)
 
@@ -659,6 +1264,8 @@ func (m model) View() string {
 	}
 	if m.fatalError != nil {
 		return errorView(m)
+	} else if !m.profileChoices.profilesChosen {
+		s = profileChoicesView(m)
 	} else if !m.databaseChoices.databasesChosen {
 		s = databaseChoicesView(m)
 	} else {
@@ -667,10 +1274,13 @@ func (m model) View() string {
 	return mainStyle.Render("\n" + s + "\n\n")
 }
 
-// The first view where user is chosing a source database
+// The view where user is chosing a source database
 func databaseChoicesView(m model) string {
 	tpl := green.Render(banner) + "\n"
 	tpl += "Choose the target and source databases"
+	if m.databaseChoices.message != "" {
+		tpl += "\n" + m.databaseChoices.message
+	}
 	tpl += "\n\n%s"
 	tpl += m.databaseChoicesHelp()
 
@@ -684,6 +1294,9 @@ func databaseChoicesView(m model) string {
 			lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.databaseChoices.sourceTable.View())),
 			lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.databaseChoices.targetTable.View())),
 		}
+		if m.prompt.active() {
+			tables = append(tables, lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.prompt.View())))
+		}
 		view = lipgloss.JoinHorizontal(lipgloss.Top, tables...)
 	}
 
@@ -699,6 +1312,10 @@ func collectionChoiceTableView(m model) string {
 	var title string
 	pad := lipgloss.NewStyle().Padding(1)
 
+	if m.collectionChoices.message != "" {
+		tpl = green.Render(banner) + "\n" + "%s\n" + m.collectionChoices.message + "\n\n%s"
+	}
+
 	if !m.collectionChoices.collectionsLoaded {
 		title = "Select a source and then a target collection"
 		spinner := fmt.Sprintf("\n %s%s\n\n", m.spinner.View(), " Fetching Collections...")
@@ -717,9 +1334,21 @@ func collectionChoiceTableView(m model) string {
 			}
 
 			title = "Remove choices or press enter to start coping data"
+			if m.collectionChoices.dryRun && !m.collectionChoices.CopyStarted {
+				title = "Dry run: press enter to preview what the copy tasks would do without writing anything"
+			}
+			if m.collectionChoices.backup != backupNone && !m.collectionChoices.CopyStarted {
+				title += fmt.Sprintf(" (targets backed up to %s)", m.collectionChoices.backup)
+			}
 			tables = []string{
 				lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.collectionChoices.copyTaskTable.View())),
 			}
+			if m.collectionChoices.preview != nil {
+				tables = append(tables, pad.Render(m.transformPreviewView()))
+			}
+			if m.prompt.active() {
+				tables = append(tables, lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.prompt.View())))
+			}
 		} else {
 			m.buildCollectionMapRows()
 			title = "Select the source collection and then the target collection"
@@ -727,6 +1356,9 @@ func collectionChoiceTableView(m model) string {
 				lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.collectionChoices.sourceTable.View())),
 				lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.collectionChoices.targetTable.View())),
 			}
+			if m.prompt.active() {
+				tables = append(tables, lipgloss.JoinVertical(lipgloss.Center, pad.Render(m.prompt.View())))
+			}
 			tpl += m.collectionChoicesHelp()
 		}
 		view = lipgloss.JoinHorizontal(lipgloss.Top, tables...)
@@ -735,6 +1367,25 @@ func collectionChoiceTableView(m model) string {
 	return fmt.Sprintf(tpl, title, view)
 }
 
+// Source document of the previewed task next to the document its copy would write
+func (m model) transformPreviewView() string {
+	p := m.collectionChoices.preview
+	name := fmt.Sprint(p.collectionId)
+	if i := m.copyTaskIndex(p.collectionId); i >= 0 {
+		name = m.collectionChoices.copyTasks[i].source.name
+	}
+
+	title := fmt.Sprintf("Preview of %s (P to close)", name)
+	if p.err != nil {
+		return title + "\n" + red.Render(p.err.Error())
+	}
+
+	pad := lipgloss.NewStyle().PaddingRight(2)
+	return title + "\n\n" + lipgloss.JoinHorizontal(lipgloss.Top,
+		pad.Render(subtleStyle.Render("source")+"\n"+p.preview.Source),
+		green.Render("written")+"\n"+p.preview.Transformed)
+}
+
 // Utils
 
 // Remove item from slice
@@ -824,24 +1475,93 @@ func (m *model) buildCollectionMapRows() {
 	tableData := []table.RowData{}
 	var status string
 
-	if !m.collectionChoices.CopyStarted {
-		status = "Not Started"
-	}
-
 	for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
-		if !m.collectionChoices.CopyStarted {
-			status = "Not Started"
-		} else {
-			status = fmt.Sprintf("%s %s", "Copying", m.collectionChoices.copyTasks[i].spinner.View())
+		task := m.collectionChoices.copyTasks[i]
+
+		switch task.state {
+		case taskRunning:
+			status = fmt.Sprintf("%s %s", task.state, task.spinner.View())
+			if task.progress.syncing {
+				status = fmt.Sprintf("Syncing, lag %s %s", task.progress.lag.Round(time.Second), task.spinner.View())
+			}
+		case taskSucceeded:
+			status = green.Render(task.state.String())
+			if task.notice != "" {
+				status += fmt.Sprintf(" (%s)", task.notice)
+			}
+		case taskFailed:
+			status = red.Render(fmt.Sprintf("%s: %s", task.state, task.err))
+		default:
+			status = task.state.String()
+		}
+
+		// Show tasks that stopped part way and can continue from their checkpoint
+		if task.state == taskPending && task.resume {
+			status = "Resume from checkpoint"
+		} else if task.state != taskRunning && task.state != taskSucceeded && m.storage.resumable(m.copySpec(task)) {
+			status += " (resumable)"
+		}
+
+		// Previews replace the status of tasks that have not been copied yet
+		if task.state == taskPending && task.previewing {
+			status = "Dry run..."
+		} else if task.state == taskPending && task.dryRunReport != nil && task.dryRunReport.Error != "" {
+			status = red.Render(task.dryRunReport.String())
+		} else if task.state == taskPending && task.dryRunReport != nil {
+			status = "Would " + task.dryRunReport.String()
+		}
+
+		// Progress is kept once the task finishes so the final count and rate stay visible
+		progress := ""
+		if task.state != taskPending && !task.started.IsZero() {
+			progress = task.progress.view(task.elapsed, progressBarWidth)
+		}
+
+		// Indexes show when they are copied until a copy reports what it did with them
+		indexes := task.indexes.String()
+		if task.indexReport != nil && task.indexReport.conflicts() {
+			indexes = red.Render(task.indexReport.String())
+		} else if task.indexReport != nil {
+			indexes = task.indexReport.String()
+		}
+
+		verify := ""
+		if task.verifying {
+			verify = "verifying..."
+		} else if task.verifyReport != nil && task.verifyReport.ok() {
+			verify = green.Render(task.verifyReport.String())
+		} else if task.verifyReport != nil {
+			verify = red.Render(task.verifyReport.String())
 		}
 
-		if m.collectionChoices.copyTasks[i].complete {
-			status = green.Render("Done")
+		mode := task.mode.String()
+		if task.mode.keyed() {
+			mode = fmt.Sprintf("%s (%s)", mode, task.writeKey())
+		} else if task.staging {
+			mode += " (staged)"
+		}
+		if task.transaction {
+			mode += " (transaction)"
+		}
+		if task.sync {
+			mode += " (sync)"
+		}
+		if task.watermark != "" {
+			mode += fmt.Sprintf(" (since %s)", task.watermark)
+		}
+		if len(task.transform) > 0 {
+			mode += fmt.Sprintf(" (%d transforms)", len(task.transform))
 		}
 
 		rowData := map[string]interface{}{
-			sourceCollectionsColumnName: m.collectionChoices.copyTasks[i].source,
-			targetCollectionsColumnName: m.collectionChoices.copyTasks[i].target,
+			copyTaskIdKey:               task.id,
+			sourceCollectionsColumnName: task.source,
+			targetCollectionsColumnName: task.target,
+			writeModeColumnName:         mode,
+			queryColumnName:             task.query.String(),
+			indexesColumnName:           indexes,
+			progressColumnName:          progress,
+			verifyColumnName:            verify,
 			CopyStatusColumnName:        status}
 		tableData = append(tableData, rowData)
 
@@ -855,15 +1575,110 @@ func (t *TableData) AddRow(row map[string]interface{}) {
 	t.Rows = append(t.Rows, row)
 }
 
-// Check if all copy tasks have completed
+// Index of the copy task with the given id, or -1 if there is none
+func (m model) copyTaskIndex(id int) int {
+	for i := range m.collectionChoices.copyTasks {
+		if m.collectionChoices.copyTasks[i].id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Index of the copy task highlighted in the copy task table, or -1 if there is none
+func (m model) highlightedCopyTaskIndex() int {
+	if m.collectionChoices.copyTaskTable.TotalRows() == 0 {
+		return -1
+	}
+
+	id, ok := m.collectionChoices.copyTaskTable.HighlightedRow().Data[copyTaskIdKey].(int)
+	if !ok {
+		return -1
+	}
+
+	return m.copyTaskIndex(id)
+}
+
+// Check if all copy tasks have finished, successfully or not
 func (m model) IsCopyTasksComplete() bool {
+	for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
+		if !m.collectionChoices.copyTasks[i].state.finished() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add a copy task from the chosen source collection to a new target collection with the given name.
+// Returns the command switching to the copy task view once every source collection has been chosen.
+func (m *model) addNewTargetCopyTask(name string) (tea.Cmd, error) {
+	if err := validCollectionName(name); err != nil {
+		return nil, err
+	}
+	if collectionIndex(m.databaseChoices.targetCollections, name) >= 0 {
+		return nil, fmt.Errorf("%s already exists, choose it from the target collections", name)
+	}
+	for _, t := range m.collectionChoices.copyTasks {
+		if t.target.name == name {
+			return nil, fmt.Errorf("%s is already the target of a copy task", name)
+		}
+	}
+
+	task := newCollectionCopyTask(m.collectionChoices.currentCopyTask.source, collection{name: name, create: true})
+	m.collectionChoices.currentCopyTask = task
+	m.collectionChoices.copyTasks = append(m.collectionChoices.copyTasks, task)
+	m.buildCollectionTableRows()
+	m.buildCollectionMapRows()
+
+	// No more source collections to choose so switch to copy task view
+	if m.collectionChoices.sourceTable.TotalRows() == 0 {
+		m.collectionChoices.sourceTable = m.collectionChoices.sourceTable.Focused(false)
+		m.collectionChoices.targetTable = m.collectionChoices.targetTable.Focused(false)
+		m.collectionChoices.copyTaskTable = m.collectionChoices.copyTaskTable.Focused(true)
+		m.collectionChoices.altscreen = true
+		return tea.EnterAltScreen, nil
+	}
+
+	// Set focus on source table again for next selection
+	m.collectionChoices.sourceTable = m.collectionChoices.sourceTable.Focused(true)
+	m.collectionChoices.targetTable = m.collectionChoices.targetTable.Focused(false)
+	return nil, nil
+}
+
+// Stop copies still in progress and close the connections to the servers, waiting a short time
+// for the stopped copies to finish their current bulk writes
+func (m model) shutdown() {
+	for i := range m.collectionChoices.copyTasks {
+		if m.collectionChoices.copyTasks[i].state.active() {
+			m.collectionChoices.copyTasks[i].cancel()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	m.storage.disconnect(ctx)
+}
+
+// Check if any copy task was cancelled
+func (m model) hasCancelledTasks() bool {
+	for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
+		if m.collectionChoices.copyTasks[i].state == taskCancelled {
+			return true
+		}
+	}
+
+	return false
+}
 
+// Check if any copy task has failed
+func (m model) hasFailedTasks() bool {
 	for i := 0; i < len(m.collectionChoices.copyTasks); i++ {
-		if m.collectionChoices.copyTasks[i].complete {
-			m.collectionChoices.completeCount++
+		if m.collectionChoices.copyTasks[i].state == taskFailed {
+			return true
 		}
 	}
 
-	var complete = m.collectionChoices.completeCount == len(m.collectionChoices.copyTasks)
-	return complete
+	return false
 }
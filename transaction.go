@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxTransactionDocuments = 10000 // Most documents copied in one transaction, larger collections are likely to hit the server's transaction limits
+
+// Shown on copies that asked for a transaction but were written without one
+const noTransactionNotice = "copied without a transaction, the target is not a replica set"
+
+// Check if a server supports multi-document transactions, which needs a replica set or a sharded cluster
+func supportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// Delete the target documents and write the source documents in a single transaction, so the target
+// either keeps its old documents or has every copied one. Copies are not paused or checkpointed part way,
+// as the transaction would have to be held open.
+func (s storage) copyTransaction(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection, spec copySpec, query parsedQuery, count int64) error {
+	if count > maxTransactionDocuments {
+		return fmt.Errorf("%d documents are too many for a transactional copy, the most is %d", count, maxTransactionDocuments)
+	}
+
+	if spec.mode.destructive() {
+		if err := s.backupTarget(ctx, tc, spec); err != nil {
+			return err
+		}
+	}
+
+	transform, err := spec.transform.parse()
+	if err != nil {
+		return err
+	}
+
+	session, err := tc.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	// The whole copy runs again when the transaction is retried after a transient error
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		spec.report(copyProgress{total: count})
+		if spec.mode.destructive() {
+			if _, err := tc.DeleteMany(sessCtx, bson.D{}); err != nil {
+				return nil, err
+			}
+		}
+
+		// Source reads use the outer context, as the session belongs to the target client
+		cursor, err := sc.Find(ctx, query.filter, query.findOptions())
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(context.Background())
+
+		var copied int64
+		batch := newWriteBatch(s.batchSize, s.batchBytes)
+		flush := func() error {
+			written := batch.len()
+			if err := s.flush(sessCtx, tc, batch); err != nil {
+				return err
+			}
+			copied += int64(written)
+			spec.report(copyProgress{copied: copied, total: count})
+			return nil
+		}
+		for cursor.Next(ctx) {
+			doc, raw, err := transform.document(cursor.Current)
+			if err != nil {
+				return nil, err
+			}
+			model, err := spec.mode.writeModel(doc, raw, spec.key)
+			if err != nil {
+				return nil, err
+			}
+
+			size := len(raw)
+			if !batch.fits(size) {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			batch.add(model, size)
+		}
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, flush()
+	}, options.Transaction())
+	if err != nil {
+		return fmt.Errorf("transaction failed, the target was left unchanged: %w", err)
+	}
+
+	return nil
+}
+
+// Check a transactional copy is not combined with options that write outside the transaction
+func (spec copySpec) validTransaction() error {
+	if spec.staging {
+		return errors.New("transactional copies cannot be staged")
+	} else if spec.resume {
+		return errors.New("transactional copies cannot be resumed, they start again")
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestValidTransaction(t *testing.T) {
+	if err := (copySpec{transaction: true}).validTransaction(); err != nil {
+		t.Errorf("expected plain transactional copy to be valid, got %v", err)
+	}
+	if err := (copySpec{transaction: true, staging: true}).validTransaction(); err == nil {
+		t.Error("expected staged transactional copy to be refused")
+	}
+	if err := (copySpec{transaction: true, resume: true}).validTransaction(); err == nil {
+		t.Error("expected resumed transactional copy to be refused")
+	}
+}
+
+func TestCopy_TransactionStaged(t *testing.T) {
+	s := newStorage("mongodb://invalid:1234", "mongodb://invalid:1234")
+	spec := copySpec{sourceDatabase: "shop", sourceCollection: "orders", targetDatabase: "shop", targetCollection: "orders", transaction: true, staging: true}
+	if _, err := s.copy(context.Background(), spec); err == nil || err.Error() != "transactional copies cannot be staged" {
+		t.Errorf("expected staged transaction error before connecting, got %v", err)
+	}
+}
+
+func TestCopyTransaction_TooManyDocuments(t *testing.T) {
+	s := newStorage("target", "source")
+	err := s.copyTransaction(context.Background(), nil, nil, copySpec{transaction: true}, parsedQuery{}, maxTransactionDocuments+1)
+	if err == nil || !strings.Contains(err.Error(), "too many for a transactional copy") {
+		t.Errorf("expected too many documents error, got %v", err)
+	}
+}
+
+// Transaction tests need a local single-node replica set, for example:
+// mongod --replSet rs0 --dbpath /tmp/rs0 && mongosh --eval "rs.initiate()"
+// MONGO_MOVE_REPLSET_URI=mongodb://localhost:27017/?replicaSet=rs0 go test -run Transaction
+func replicaSetClient(t *testing.T) (*mongo.Client, string) {
+	uri := os.Getenv("MONGO_MOVE_REPLSET_URI")
+	if uri == "" {
+		t.Skip("MONGO_MOVE_REPLSET_URI not set")
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Database("mongo_move_txn").Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+
+	return client, uri
+}
+
+// Seed a collection with documents numbered from 0
+func seedCollection(t *testing.T, c *mongo.Collection, n int, field string) {
+	t.Helper()
+	c.Drop(context.Background())
+	docs := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		docs = append(docs, bson.D{{Key: field, Value: i}})
+	}
+	if _, err := c.InsertMany(context.Background(), docs); err != nil {
+		t.Fatalf("failed to seed %s: %v", c.Name(), err)
+	}
+}
+
+func TestCopyTransaction_ReplicaSet(t *testing.T) {
+	client, uri := replicaSetClient(t)
+	db := client.Database("mongo_move_txn")
+	seedCollection(t, db.Collection("source"), 50, "n")
+	seedCollection(t, db.Collection("target"), 10, "old")
+
+	ok, err := supportsTransactions(context.Background(), client)
+	if err != nil || !ok {
+		t.Fatalf("expected replica set to support transactions, got %v, %v", ok, err)
+	}
+
+	s := newStorage(uri, uri).withBatching(20, 0)
+	spec := copySpec{sourceDatabase: "mongo_move_txn", sourceCollection: "source", targetDatabase: "mongo_move_txn", targetCollection: "target", transaction: true}
+	report, err := s.copy(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("expected transactional copy to succeed, got %v", err)
+	}
+	if report.notice != "" {
+		t.Errorf("expected copy in a transaction, got notice %q", report.notice)
+	}
+
+	if count, _ := db.Collection("target").CountDocuments(context.Background(), bson.D{}); count != 50 {
+		t.Errorf("expected 50 target documents, got %d", count)
+	}
+}
+
+func TestCopyTransaction_RollsBack(t *testing.T) {
+	client, uri := replicaSetClient(t)
+	db := client.Database("mongo_move_txn")
+	seedCollection(t, db.Collection("source"), 50, "n")
+	seedCollection(t, db.Collection("target"), 10, "n")
+
+	// Every source document has the same code, so the second one written breaks the unique index
+	if _, err := db.Collection("source").UpdateMany(context.Background(), bson.D{}, bson.D{{Key: "$set", Value: bson.D{{Key: "code", Value: "x"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	unique := mongo.IndexModel{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)}
+	if _, err := db.Collection("target").Indexes().CreateOne(context.Background(), unique); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newStorage(uri, uri).withBatching(20, 0)
+	spec := copySpec{sourceDatabase: "mongo_move_txn", sourceCollection: "source", targetDatabase: "mongo_move_txn", targetCollection: "target", transaction: true}
+	if _, err := s.copy(context.Background(), spec); err == nil {
+		t.Fatal("expected copy breaking the unique index to fail")
+	}
+
+	if count, _ := db.Collection("target").CountDocuments(context.Background(), bson.D{}); count != 10 {
+		t.Errorf("expected target to keep its 10 documents, got %d", count)
+	}
+}
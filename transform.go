@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Operations of a transformation step
+const (
+	transformRename  = "rename"  // Move a field to another path
+	transformDrop    = "drop"    // Remove a field
+	transformSet     = "set"     // Give a field a constant value
+	transformConvert = "convert" // Change the type of a field's value
+)
+
+// Types a convert step changes values to
+var transformTypes = []string{"string", "int", "long", "double", "decimal", "bool", "date", "objectId"}
+
+// One step of a transformation pipeline, as declared in a plan task. Fields are dotted paths into
+// embedded documents.
+type fieldTransform struct {
+	Op    string          `json:"op"`              // rename, drop, set or convert
+	Field string          `json:"field"`           // Path of the field changed
+	To    string          `json:"to,omitempty"`    // Path a renamed field is moved to
+	Value json.RawMessage `json:"value,omitempty"` // Extended JSON value a set field is given
+	Type  string          `json:"type,omitempty"`  // Type a converted field is changed to
+}
+
+// Steps applied in order to every source document before it is written to the target
+type transformPipeline []fieldTransform
+
+// Parsed transformation step ready to apply to documents
+type transformStep struct {
+	op    string
+	field []string
+	to    []string
+	value interface{}
+	typ   string
+}
+
+// Parsed transformation pipeline. The zero value leaves documents unchanged.
+type parsedTransform struct {
+	steps []transformStep
+}
+
+// Parse and check every step of the pipeline
+func (p transformPipeline) parse() (parsedTransform, error) {
+	var parsed parsedTransform
+	for i, t := range p {
+		step, err := t.parse()
+		if err != nil {
+			return parsed, fmt.Errorf("transform step %d: %w", i+1, err)
+		}
+		parsed.steps = append(parsed.steps, step)
+	}
+
+	return parsed, nil
+}
+
+func (t fieldTransform) parse() (transformStep, error) {
+	step := transformStep{op: t.Op, typ: t.Type}
+	var err error
+	if step.field, err = parsePath(t.Field); err != nil {
+		return step, err
+	}
+
+	switch t.Op {
+	case transformRename:
+		if step.to, err = parsePath(t.To); err != nil {
+			return step, fmt.Errorf("rename target: %w", err)
+		}
+	case transformDrop:
+	case transformSet:
+		if len(t.Value) == 0 {
+			return step, errors.New("set needs a value")
+		}
+		// Values are decoded in a wrapper document, as extended JSON has no bare values
+		var wrapper bson.D
+		if err := bson.UnmarshalExtJSON([]byte(`{"v":`+string(t.Value)+`}`), false, &wrapper); err != nil {
+			return step, fmt.Errorf("invalid set value: %w", err)
+		}
+		step.value = wrapper[0].Value
+	case transformConvert:
+		if !validTransformType(t.Type) {
+			return step, fmt.Errorf("unknown convert type %q, expected one of %s", t.Type, strings.Join(transformTypes, ", "))
+		}
+	default:
+		return step, fmt.Errorf("unknown operation %q, expected rename, drop, set or convert", t.Op)
+	}
+
+	return step, nil
+}
+
+// Split a dotted field path, refusing empty and operator names
+func parsePath(field string) ([]string, error) {
+	if field == "" {
+		return nil, errors.New("field is missing")
+	}
+
+	path := strings.Split(field, ".")
+	for _, name := range path {
+		if name == "" || strings.HasPrefix(name, "$") {
+			return nil, fmt.Errorf("invalid field path %q", field)
+		}
+	}
+	return path, nil
+}
+
+func validTransformType(typ string) bool {
+	for _, t := range transformTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if any step changes the _id, which syncs match target documents on
+func (p transformPipeline) changesID() bool {
+	for _, t := range p {
+		for _, field := range []string{t.Field, t.To} {
+			if field == defaultWriteKey || strings.HasPrefix(field, defaultWriteKey+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply the steps to a document in order. Fields missing from the document are left alone,
+// except by set, which creates them and any embedded documents on their path.
+func (t parsedTransform) apply(doc bson.D) (bson.D, error) {
+	var err error
+	for _, step := range t.steps {
+		switch step.op {
+		case transformRename:
+			var value interface{}
+			var ok bool
+			if doc, value, ok = removePath(doc, step.field); ok {
+				doc, err = setPath(doc, step.to, value)
+			}
+		case transformDrop:
+			doc, _, _ = removePath(doc, step.field)
+		case transformSet:
+			doc, err = setPath(doc, step.field, step.value)
+		case transformConvert:
+			if value, ok := lookupPath(doc, step.field); ok && value != nil {
+				if value, err = convertValue(value, step.typ); err == nil {
+					doc, err = setPath(doc, step.field, value)
+				}
+			}
+		}
+		if err != nil {
+			return doc, fmt.Errorf("%s %s: %w", step.op, strings.Join(step.field, "."), err)
+		}
+	}
+
+	return doc, nil
+}
+
+// Decode a source document and apply the steps, returning the document to write and its encoding.
+// Documents are only encoded again when there are steps.
+func (t parsedTransform) document(raw bson.Raw) (bson.D, bson.Raw, error) {
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(t.steps) == 0 {
+		return doc, raw, nil
+	}
+
+	doc, err := t.apply(doc)
+	if err != nil {
+		if id, ok := lookupPath(doc, []string{defaultWriteKey}); ok {
+			return nil, nil, fmt.Errorf("failed to transform document %v: %w", id, err)
+		}
+		return nil, nil, fmt.Errorf("failed to transform document: %w", err)
+	}
+	out, err := bson.Marshal(doc)
+	return doc, out, err
+}
+
+// Value at a path, if every document on the way has the field
+func lookupPath(doc bson.D, path []string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return e.Value, true
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return nil, false
+		}
+		return lookupPath(sub, path[1:])
+	}
+
+	return nil, false
+}
+
+// Copy of doc with the value at a path, added at the end of its document if the field is new.
+// Documents on the path are copied rather than changed, as set values are shared between documents.
+func setPath(doc bson.D, path []string, value interface{}) (bson.D, error) {
+	out := append(make(bson.D, 0, len(doc)+1), doc...)
+	for i, e := range out {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			out[i].Value = value
+			return out, nil
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return doc, fmt.Errorf("%s is not a document", path[0])
+		}
+		sub, err := setPath(sub, path[1:], value)
+		if err != nil {
+			return doc, err
+		}
+		out[i].Value = sub
+		return out, nil
+	}
+
+	if len(path) == 1 {
+		return append(out, bson.E{Key: path[0], Value: value}), nil
+	}
+	sub, err := setPath(bson.D{}, path[1:], value)
+	if err != nil {
+		return doc, err
+	}
+	return append(out, bson.E{Key: path[0], Value: sub}), nil
+}
+
+// Copy of doc without the field at a path, with the removed value if it was there
+func removePath(doc bson.D, path []string) (bson.D, interface{}, bool) {
+	for i, e := range doc {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			out := append(make(bson.D, 0, len(doc)-1), doc[:i]...)
+			return append(out, doc[i+1:]...), e.Value, true
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return doc, nil, false
+		}
+		sub, value, ok := removePath(sub, path[1:])
+		if !ok {
+			return doc, nil, false
+		}
+		out := append(make(bson.D, 0, len(doc)), doc...)
+		out[i].Value = sub
+		return out, value, true
+	}
+
+	return doc, nil, false
+}
+
+// Convert a decoded value to one of the transform types
+func convertValue(v interface{}, typ string) (interface{}, error) {
+	switch typ {
+	case "string":
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case int32, int64:
+			return fmt.Sprint(x), nil
+		case float64:
+			return strconv.FormatFloat(x, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(x), nil
+		case primitive.Decimal128:
+			return x.String(), nil
+		case primitive.ObjectID:
+			return x.Hex(), nil
+		case primitive.DateTime:
+			return x.Time().UTC().Format(time.RFC3339Nano), nil
+		}
+	case "int", "long":
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "long" {
+			return n, nil
+		} else if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("%d does not fit in an int", n)
+		}
+		return int32(n), nil
+	case "double":
+		return toFloat64(v)
+	case "decimal":
+		s, err := convertValue(v, "string")
+		if err != nil {
+			return nil, err
+		}
+		return primitive.ParseDecimal128(s.(string))
+	case "bool":
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case int32:
+			return x != 0, nil
+		case int64:
+			return x != 0, nil
+		case float64:
+			return x != 0, nil
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(x))
+		}
+	case "date":
+		switch x := v.(type) {
+		case primitive.DateTime:
+			return x, nil
+		case int32:
+			return primitive.DateTime(x), nil
+		case int64:
+			return primitive.DateTime(x), nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(x))
+			if err != nil {
+				return nil, err
+			}
+			return primitive.NewDateTimeFromTime(t), nil
+		}
+	case "objectId":
+		switch x := v.(type) {
+		case primitive.ObjectID:
+			return x, nil
+		case string:
+			return primitive.ObjectIDFromHex(strings.TrimSpace(x))
+		}
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to %s", typeName(v), typ)
+}
+
+// Integer value of a number, numeric string or bool. Numbers with a fraction are refused rather than rounded.
+func toInt64(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case int32:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case float64:
+		if x != math.Trunc(x) || x < math.MinInt64 || x > math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not a whole number", x)
+		}
+		return int64(x), nil
+	case primitive.Decimal128:
+		return strconv.ParseInt(x.String(), 10, 64)
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("cannot convert %s to a number", typeName(v))
+}
+
+// Floating point value of a number, numeric string or bool
+func toFloat64(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case int32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case primitive.Decimal128:
+		return strconv.ParseFloat(x.String(), 64)
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(x), 64)
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("cannot convert %s to a number", typeName(v))
+}
+
+// BSON type name of a decoded value
+func typeName(v interface{}) string {
+	typ, _, err := bson.MarshalValue(v)
+	if err != nil {
+		return fmt.Sprintf("%T", v)
+	}
+	return typ.String()
+}
+
+// One source document next to the document the copy would write, as relaxed extended JSON
+type transformPreview struct {
+	Source      string `json:"source"`
+	Transformed string `json:"transformed"`
+}
+
+// Read one source document the copy would read and apply the task's transformation to it,
+// only reading from the source
+func (s storage) previewTransform(ctx context.Context, spec copySpec) (transformPreview, error) {
+	var preview transformPreview
+
+	query, err := spec.query.parse()
+	if err != nil {
+		return preview, err
+	}
+	transform, err := spec.transform.parse()
+	if err != nil {
+		return preview, err
+	}
+
+	sClient, releaseSource, err := s.source(ctx)
+	if err != nil {
+		return preview, err
+	}
+	defer releaseSource()
+
+	opts := options.FindOne()
+	if len(query.sort) > 0 {
+		opts.SetSort(query.sort)
+	}
+	if len(query.projection) > 0 {
+		opts.SetProjection(query.projection)
+	}
+	sc := sClient.Database(spec.sourceDatabase).Collection(spec.sourceCollection)
+	raw, err := sc.FindOne(ctx, query.and(bson.D{}), opts).Raw()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return preview, errors.New("no source documents to preview")
+	} else if err != nil {
+		return preview, err
+	}
+
+	_, transformed, err := transform.document(raw)
+	if err != nil {
+		return preview, err
+	}
+
+	source, err := bson.MarshalExtJSONIndent(raw, false, false, "", "  ")
+	if err != nil {
+		return preview, err
+	}
+	target, err := bson.MarshalExtJSONIndent(transformed, false, false, "", "  ")
+	if err != nil {
+		return preview, err
+	}
+	preview.Source, preview.Transformed = string(source), string(target)
+	return preview, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTransformPipelineParse_Invalid(t *testing.T) {
+	tests := map[string]transformPipeline{
+		"operation":  {{Op: "merge", Field: "a"}},
+		"field":      {{Op: transformDrop}},
+		"path":       {{Op: transformDrop, Field: "a..b"}},
+		"operator":   {{Op: transformDrop, Field: "$where"}},
+		"rename":     {{Op: transformRename, Field: "a"}},
+		"set":        {{Op: transformSet, Field: "a"}},
+		"set value":  {{Op: transformSet, Field: "a", Value: []byte(`{bad`)}},
+		"convert to": {{Op: transformConvert, Field: "a", Type: "uuid"}},
+	}
+
+	for name, p := range tests {
+		if _, err := p.parse(); err == nil || !strings.HasPrefix(err.Error(), "transform step 1: ") {
+			t.Errorf("%s: expected step error, got %v", name, err)
+		}
+	}
+}
+
+func TestParsedTransformApply(t *testing.T) {
+	p := transformPipeline{
+		{Op: transformRename, Field: "name", To: "profile.fullName"},
+		{Op: transformDrop, Field: "legacy.flag"},
+		{Op: transformSet, Field: "meta.source", Value: []byte(`"import"`)},
+		{Op: transformConvert, Field: "age", Type: "int"},
+		{Op: transformConvert, Field: "missing", Type: "int"},
+	}
+	transform, err := p.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := bson.D{
+		{Key: "_id", Value: int32(1)},
+		{Key: "name", Value: "Ada"},
+		{Key: "age", Value: "36"},
+		{Key: "legacy", Value: bson.D{{Key: "flag", Value: true}, {Key: "code", Value: "x"}}},
+	}
+	got, err := transform.apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bson.D{
+		{Key: "_id", Value: int32(1)},
+		{Key: "age", Value: int32(36)},
+		{Key: "legacy", Value: bson.D{{Key: "code", Value: "x"}}},
+		{Key: "profile", Value: bson.D{{Key: "fullName", Value: "Ada"}}},
+		{Key: "meta", Value: bson.D{{Key: "source", Value: "import"}}},
+	}
+	if !equalFilters(t, got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// The source document is left as it was
+	if v, ok := lookupPath(doc, []string{"legacy", "flag"}); !ok || v != true {
+		t.Errorf("expected source document to be unchanged, got %v", doc)
+	}
+}
+
+func TestParsedTransformApply_Errors(t *testing.T) {
+	transform, err := transformPipeline{{Op: transformConvert, Field: "age", Type: "int"}}.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transform.apply(bson.D{{Key: "age", Value: "old"}}); err == nil || !strings.HasPrefix(err.Error(), "convert age: ") {
+		t.Errorf("expected convert error, got %v", err)
+	}
+
+	transform, err = transformPipeline{{Op: transformSet, Field: "name.first", Value: []byte(`"Ada"`)}}.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transform.apply(bson.D{{Key: "name", Value: "Ada"}}); err == nil {
+		t.Error("expected set inside a string to fail")
+	}
+}
+
+func TestParsedTransformDocument(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{{Key: "_id", Value: int32(1)}, {Key: "code", Value: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Documents are not encoded again without steps
+	_, out, err := parsedTransform{}.document(raw)
+	if err != nil || &out[0] != &raw[0] {
+		t.Errorf("expected the source encoding to be reused, got %v", err)
+	}
+
+	transform, err := transformPipeline{{Op: transformRename, Field: "code", To: "sku"}}.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, out, err = transform.document(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := out.LookupErr("sku"); err != nil || v.StringValue() != "a" {
+		t.Errorf("expected renamed field in encoded document, got %s", out)
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		value interface{}
+		typ   string
+		want  interface{}
+	}{
+		{int32(7), "string", "7"},
+		{1.5, "string", "1.5"},
+		{id, "string", id.Hex()},
+		{"42", "long", int64(42)},
+		{4.0, "int", int32(4)},
+		{int32(3), "double", 3.0},
+		{"true", "bool", true},
+		{int64(0), "bool", false},
+		{id.Hex(), "objectId", id},
+		{int64(1000), "date", primitive.DateTime(1000)},
+		{"1970-01-01T00:00:01Z", "date", primitive.DateTime(1000)},
+	}
+	for _, tt := range tests {
+		got, err := convertValue(tt.value, tt.typ)
+		if err != nil || got != tt.want {
+			t.Errorf("convert %v to %s: expected %v, got %v, %v", tt.value, tt.typ, tt.want, got, err)
+		}
+	}
+
+	if got, err := convertValue("12.50", "decimal"); err != nil || got.(primitive.Decimal128).String() != "12.50" {
+		t.Errorf("expected decimal 12.50, got %v, %v", got, err)
+	}
+
+	for _, bad := range []struct {
+		value interface{}
+		typ   string
+	}{{4.5, "int"}, {int64(1) << 40, "int"}, {true, "objectId"}, {bson.D{}, "string"}} {
+		if _, err := convertValue(bad.value, bad.typ); err == nil {
+			t.Errorf("expected converting %v to %s to fail", bad.value, bad.typ)
+		}
+	}
+}
+
+func TestTransformPipeline_ChangesID(t *testing.T) {
+	if (transformPipeline{{Op: transformRename, Field: "code", To: "sku"}}).changesID() {
+		t.Error("expected rename of another field not to change the _id")
+	}
+	if !(transformPipeline{{Op: transformConvert, Field: "_id", Type: "string"}}).changesID() {
+		t.Error("expected convert of the _id to change it")
+	}
+	if !(transformPipeline{{Op: transformRename, Field: "legacyId", To: "_id"}}).changesID() {
+		t.Error("expected rename to the _id to change it")
+	}
+	if err := (copySpec{transform: transformPipeline{{Op: transformDrop, Field: "_id.region"}}}).validSync(); err == nil {
+		t.Error("expected sync transforming the _id to be refused")
+	}
+}
+
+func TestUpdate_TransformPreview(t *testing.T) {
+	m := model{keyBindings: keyModel{keys: keys}}
+	m.profileChoices.profilesChosen = true
+	m.databaseChoices.databasesChosen = true
+	m.collectionChoices.altscreen = true
+	m.collectionChoices.copyTaskTable = m.collectionChoices.copyTaskTable.Focused(true)
+	m.collectionChoices.copyTasks = []collectionCopyTask{{id: 5, source: collection{name: "orders"}}}
+
+	updated, _ := m.Update(transformPreviewMsg{collectionId: 5, preview: transformPreview{Source: `{"a": 1}`, Transformed: `{"b": 1}`}})
+	m = updated.(model)
+	if m.collectionChoices.preview == nil {
+		t.Fatal("expected preview to be shown")
+	}
+	if view := m.transformPreviewView(); !strings.Contains(view, "orders") || !strings.Contains(view, `{"b": 1}`) {
+		t.Errorf("expected preview of orders, got %q", view)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("P")})
+	m = updated.(model)
+	if m.collectionChoices.preview != nil {
+		t.Error("expected P to hide the preview")
+	}
+}
+
+func TestPreviewTransform(t *testing.T) {
+	client, uri := replicaSetClient(t)
+	db := client.Database("mongo_move_txn")
+	seedCollection(t, db.Collection("source"), 3, "n")
+
+	s := newStorage(uri, uri)
+	spec := copySpec{
+		sourceDatabase:   "mongo_move_txn",
+		sourceCollection: "source",
+		query:            copyQuery{filter: `{"n":2}`},
+		transform:        transformPipeline{{Op: transformConvert, Field: "n", Type: "string"}},
+	}
+	preview, err := s.previewTransform(context.Background(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(preview.Source, `"n": 2`) || !strings.Contains(preview.Transformed, `"n": "2"`) {
+		t.Errorf("expected n converted to a string, got %+v", preview)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultVerifySamples    = 100                      // Documents compared one by one when not set in config
+	defaultVerifyReportFile = "mongo-move.verify.json" // Local file verify reports are saved to when not set in config
+
+	verifyDBHash    = "dbHash"    // Collections compared with the server's dbHash command
+	verifyDocuments = "documents" // Collections compared with a hash of every document read
+)
+
+// Settings of the checks run after a copy
+type verifySettings struct {
+	samples    int    // Random source documents compared with their target documents
+	reportFile string // File the verify reports are saved to
+}
+
+// Set how many documents are compared one by one and where reports are saved, keeping the defaults for values that are not set
+func (s storage) withVerify(samples int, reportFile string) storage {
+	if samples > 0 {
+		s.verify.samples = samples
+	}
+	if reportFile != "" {
+		s.verify.reportFile = reportFile
+	}
+	return s
+}
+
+// Result of comparing a copied collection with its source
+type verifyReport struct {
+	Source      string    `json:"source"`
+	Target      string    `json:"target"`
+	Verified    time.Time `json:"verified"`
+	SourceCount int64     `json:"sourceCount"`
+	TargetCount int64     `json:"targetCount"`
+	Method      string    `json:"method"` // How the hashes were computed, dbHash or documents
+	SourceHash  string    `json:"sourceHash"`
+	TargetHash  string    `json:"targetHash"`
+	Sampled     int       `json:"sampled"`             // Source documents compared with their target documents
+	Missing     []string  `json:"missing,omitempty"`   // _id of sampled documents not found in the target
+	Different   []string  `json:"different,omitempty"` // _id of sampled documents that differ in the target
+	Error       string    `json:"error,omitempty"`     // Why the checks could not be finished
+}
+
+// Check if every check passed
+func (r verifyReport) ok() bool {
+	return r.Error == "" &&
+		r.SourceCount == r.TargetCount &&
+		r.SourceHash == r.TargetHash &&
+		len(r.Missing) == 0 &&
+		len(r.Different) == 0
+}
+
+// Short summary for the copy task table and command output
+func (r verifyReport) String() string {
+	if r.Error != "" {
+		return "verify failed: " + r.Error
+	}
+	if r.ok() {
+		return fmt.Sprintf("verified %d documents, %d sampled", r.TargetCount, r.Sampled)
+	}
+
+	var problems []string
+	if r.SourceCount != r.TargetCount {
+		problems = append(problems, fmt.Sprintf("counts differ %d/%d", r.SourceCount, r.TargetCount))
+	}
+	if r.SourceHash != r.TargetHash {
+		problems = append(problems, "hashes differ")
+	}
+	if n := len(r.Missing) + len(r.Different); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d of %d sampled differ", n, r.Sampled))
+	}
+
+	return strings.Join(problems, ", ")
+}
+
+// Order independent hash of a set of documents, the sum of the SHA-256 of each document's BSON
+type documentHash [4]uint64
+
+func (h *documentHash) add(doc []byte) {
+	sum := sha256.Sum256(doc)
+	for i := range h {
+		h[i] += binary.BigEndian.Uint64(sum[i*8:])
+	}
+}
+
+func (h documentHash) String() string {
+	return fmt.Sprintf("%016x%016x%016x%016x", h[0], h[1], h[2], h[3])
+}
+
+// Compare the copied documents of a target collection with the source documents the copy read:
+// the document counts, a hash of the whole collections and a sample of single documents.
+// Failed checks are recorded in the report, the error is only set when a check could not be run.
+func (s storage) verifyCopy(ctx context.Context, spec copySpec) (verifyReport, error) {
+	report := verifyReport{
+		Source:   spec.sourceDatabase + "." + spec.sourceCollection,
+		Target:   spec.targetDatabase + "." + spec.targetCollection,
+		Verified: time.Now().UTC(),
+	}
+
+	sClient, releaseSource, err := s.source(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseSource()
+
+	tClient, releaseTarget, err := s.target(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer releaseTarget()
+
+	sc := sClient.Database(spec.sourceDatabase).Collection(spec.sourceCollection)
+	tc := tClient.Database(spec.targetDatabase).Collection(spec.targetCollection)
+
+	query, err := spec.query.parse()
+	if err != nil {
+		return report, err
+	}
+	transform, err := spec.transform.parse()
+	if err != nil {
+		return report, err
+	}
+
+	if report.SourceCount, err = s.getRecordCount(ctx, sClient, spec.sourceDatabase, spec.sourceCollection, query.and(bson.D{}), query.countOptions()); err != nil {
+		return report, fmt.Errorf("failed to count source documents: %w", err)
+	}
+	if report.TargetCount, err = s.getRecordCount(ctx, tClient, spec.targetDatabase, spec.targetCollection, bson.D{}); err != nil {
+		return report, fmt.Errorf("failed to count target documents: %w", err)
+	}
+
+	// dbHash covers whole collections, so it can only be compared when every document was copied unchanged
+	// with its _id. Servers without it, such as mongos, fall back to hashing the documents read.
+	key := verifyKey(spec)
+	report.Method = verifyDocuments
+	if spec.query.empty() && key == defaultWriteKey && len(spec.transform) == 0 {
+		sourceHash, sourceErr := dbHash(ctx, sc)
+		targetHash, targetErr := dbHash(ctx, tc)
+		if sourceErr == nil && targetErr == nil {
+			report.Method = verifyDBHash
+			report.SourceHash = sourceHash
+			report.TargetHash = targetHash
+		}
+	}
+	if report.Method == verifyDocuments {
+		withoutID := key != defaultWriteKey
+		if report.SourceHash, err = hashDocuments(ctx, sc, query.and(bson.D{}), query.findOptions(), transform, withoutID); err != nil {
+			return report, fmt.Errorf("failed to hash source documents: %w", err)
+		}
+		if report.TargetHash, err = hashDocuments(ctx, tc, bson.D{}, options.Find(), parsedTransform{}, withoutID); err != nil {
+			return report, fmt.Errorf("failed to hash target documents: %w", err)
+		}
+	}
+
+	if err := s.sampleDocuments(ctx, sc, tc, query, transform, key, &report); err != nil {
+		return report, fmt.Errorf("failed to compare sampled documents: %w", err)
+	}
+
+	return report, nil
+}
+
+// Verify a copy, recording an error that stopped the checks in the report
+func (s storage) verifyResult(ctx context.Context, spec copySpec) *verifyReport {
+	report, err := s.verifyCopy(ctx, spec)
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return &report
+}
+
+// Hash of a collection from the dbHash command
+func dbHash(ctx context.Context, c *mongo.Collection) (string, error) {
+	var result struct {
+		Collections map[string]string `bson:"collections"`
+	}
+	cmd := bson.D{{Key: "dbHash", Value: 1}, {Key: "collections", Value: bson.A{c.Name()}}}
+	if err := c.Database().RunCommand(ctx, cmd).Decode(&result); err != nil {
+		return "", err
+	}
+
+	hash, ok := result.Collections[c.Name()]
+	if !ok {
+		return "", errors.New("dbHash did not return a hash for the collection")
+	}
+
+	return hash, nil
+}
+
+// Field source and target documents are matched on. Keyed write modes leave the source _id out
+// when matching on another field, so target documents keep their own _id.
+func verifyKey(spec copySpec) string {
+	if spec.mode.keyed() && spec.key != "" {
+		return spec.key
+	}
+
+	return defaultWriteKey
+}
+
+// Encoded document without its _id
+func withoutID(doc bson.Raw) ([]byte, error) {
+	var d bson.D
+	if err := bson.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+
+	return bson.Marshal(withoutField(d, defaultWriteKey))
+}
+
+// Hash of the documents matching a filter as the copy writes them, read in any order, leaving out their _id if set
+func hashDocuments(ctx context.Context, c *mongo.Collection, filter bson.D, opts *options.FindOptions, transform parsedTransform, skipID bool) (string, error) {
+	cursor, err := c.Find(ctx, filter, opts)
+	if err != nil {
+		return "", err
+	}
+	defer cursor.Close(context.Background())
+
+	var hash documentHash
+	for cursor.Next(ctx) {
+		_, raw, err := transform.document(cursor.Current)
+		if err != nil {
+			return "", err
+		}
+		doc := []byte(raw)
+		if skipID {
+			if doc, err = withoutID(raw); err != nil {
+				return "", err
+			}
+		}
+		hash.add(doc)
+	}
+	if err := cursor.Err(); err != nil {
+		return "", err
+	}
+
+	return hash.String(), nil
+}
+
+// Compare random source documents, transformed as the copy writes them, with the target documents that
+// have the same key field value, leaving out the _id when matching on another field
+func (s storage) sampleDocuments(ctx context.Context, sc *mongo.Collection, tc *mongo.Collection, query parsedQuery, transform parsedTransform, key string, report *verifyReport) error {
+	if s.verify.samples <= 0 {
+		return nil
+	}
+
+	cursor, err := sc.Aggregate(ctx, samplePipeline(query, s.verify.samples))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		id := cursor.Current.Lookup("_id")
+		report.Sampled++
+
+		_, source, err := transform.document(cursor.Current)
+		if err != nil {
+			return err
+		}
+		value, err := source.LookupErr(strings.Split(key, ".")...)
+		if err != nil {
+			report.Missing = append(report.Missing, id.String())
+			continue
+		}
+
+		target, err := tc.FindOne(ctx, bson.D{{Key: key, Value: value}}).Raw()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			report.Missing = append(report.Missing, id.String())
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		same, err := sameDocument(source, target, key != defaultWriteKey)
+		if err != nil {
+			return err
+		} else if !same {
+			report.Different = append(report.Different, id.String())
+		}
+	}
+
+	return cursor.Err()
+}
+
+// Pipeline sampling the source documents a query copies. Sorted and limited queries only copy the first
+// documents in their order, so those are chosen before sampling.
+func samplePipeline(query parsedQuery, samples int) mongo.Pipeline {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: query.and(bson.D{})}}}
+	if len(query.sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: query.sort}})
+	}
+	if query.limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: query.limit}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: samples}}}})
+	if len(query.projection) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: query.projection}})
+	}
+
+	return pipeline
+}
+
+// Check if two documents are equal, field order included, leaving out their _id if set
+func sameDocument(source bson.Raw, target bson.Raw, skipID bool) (bool, error) {
+	if !skipID {
+		return bytes.Equal(source, target), nil
+	}
+
+	s, err := withoutID(source)
+	if err != nil {
+		return false, err
+	}
+	t, err := withoutID(target)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(s, t), nil
+}
+
+// Save verify reports to the report file, replacing the reports saved before
+func (s storage) saveVerifyReports(reports []verifyReport) error {
+	return saveJSON(s.verify.reportFile, reports)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithVerify(t *testing.T) {
+	s := newStorage("target", "source")
+	if s.verify.samples != defaultVerifySamples || s.verify.reportFile != defaultVerifyReportFile {
+		t.Errorf("expected default verify settings, got %+v", s.verify)
+	}
+
+	s = s.withVerify(20, "reports.json")
+	if s.verify.samples != 20 || s.verify.reportFile != "reports.json" {
+		t.Errorf("expected verify settings to be set, got %+v", s.verify)
+	}
+
+	s = s.withVerify(0, "")
+	if s.verify.samples != 20 || s.verify.reportFile != "reports.json" {
+		t.Errorf("expected unset values to keep the settings, got %+v", s.verify)
+	}
+}
+
+func TestDocumentHash_OrderIndependent(t *testing.T) {
+	var docs [][]byte
+	for i := 0; i < 3; i++ {
+		doc, err := bson.Marshal(bson.D{{Key: "_id", Value: i}, {Key: "name", Value: "doc"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+
+	var forward, backward documentHash
+	for i := range docs {
+		forward.add(docs[i])
+		backward.add(docs[len(docs)-1-i])
+	}
+	if forward != backward {
+		t.Errorf("expected hash not to depend on order, got %s and %s", forward, backward)
+	}
+
+	var missing documentHash
+	missing.add(docs[0])
+	missing.add(docs[1])
+	if missing == forward {
+		t.Error("expected a missing document to change the hash")
+	}
+	if len(forward.String()) != 64 {
+		t.Errorf("expected 64 hex characters, got %s", forward)
+	}
+}
+
+func TestVerifyReport(t *testing.T) {
+	r := verifyReport{SourceCount: 10, TargetCount: 10, SourceHash: "a", TargetHash: "a", Sampled: 5}
+	if !r.ok() || r.String() != "verified 10 documents, 5 sampled" {
+		t.Errorf("expected matching report to pass, got %s", r)
+	}
+
+	r.TargetCount = 9
+	r.TargetHash = "b"
+	r.Missing = []string{"1"}
+	if r.ok() || r.String() != "counts differ 10/9, hashes differ, 1 of 5 sampled differ" {
+		t.Errorf("expected report to list its problems, got %s", r)
+	}
+
+	r = verifyReport{Error: "connection refused"}
+	if r.ok() || r.String() != "verify failed: connection refused" {
+		t.Errorf("expected failed report, got %s", r)
+	}
+}
+
+func TestSaveVerifyReports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verify.json")
+	s := newStorage("target", "source").withVerify(0, path)
+
+	reports := []verifyReport{{Source: "shop.orders", Target: "shop.orders", SourceCount: 3, TargetCount: 3}}
+	if err := s.saveVerifyReports(reports); err != nil {
+		t.Fatalf("failed to save reports: %v", err)
+	}
+
+	loaded, err := loadJSON[[]verifyReport](path)
+	if err != nil {
+		t.Fatalf("failed to load reports: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].Source != "shop.orders" || loaded[0].TargetCount != 3 {
+		t.Errorf("unexpected saved reports %v", loaded)
+	}
+}
+
+func TestStartVerifyTask(t *testing.T) {
+	m := model{}
+	m.collectionChoices.copyTasks = []collectionCopyTask{{id: 1, state: taskRunning}, {id: 2, state: taskSucceeded, attempt: 1}}
+
+	if cmd := m.startVerifyTask(0); cmd != nil {
+		t.Error("expected running task not to be verified")
+	}
+	if cmd := m.startVerifyTask(1); cmd == nil || !m.collectionChoices.copyTasks[1].verifying {
+		t.Fatal("expected succeeded task to be verified")
+	}
+	if cmd := m.startVerifyTask(1); cmd != nil {
+		t.Error("expected task being verified not to be verified again")
+	}
+}
+
+func TestUpdate_VerifyMsg(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verify.json")
+	m := model{storage: newStorage("target", "source").withVerify(0, path)}
+	m.collectionChoices.copyTasks = []collectionCopyTask{{id: 2, state: taskSucceeded, attempt: 1, verifying: true}}
+
+	updated, _ := m.Update(verifyMsg{collectionId: 2, attempt: 1, report: verifyReport{Source: "shop.orders", SourceCount: 1, TargetCount: 1}})
+	task := updated.(model).collectionChoices.copyTasks[0]
+	if task.verifying || task.verifyReport == nil || !task.verifyReport.ok() {
+		t.Errorf("expected task to hold the verify report, got %+v", task.verifyReport)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected verify reports to be saved: %v", err)
+	}
+}
+
+func TestVerifyKey(t *testing.T) {
+	if k := verifyKey(copySpec{mode: modeReplace, key: "code"}); k != "_id" {
+		t.Errorf("expected unkeyed mode to match on _id, got %s", k)
+	}
+	if k := verifyKey(copySpec{mode: modeUpsert, key: "code"}); k != "code" {
+		t.Errorf("expected keyed mode to match on its key, got %s", k)
+	}
+	if k := verifyKey(copySpec{mode: modeInsertMissing}); k != "_id" {
+		t.Errorf("expected keyed mode without key to match on _id, got %s", k)
+	}
+}
+
+func TestSameDocument(t *testing.T) {
+	source, _ := bson.Marshal(bson.D{{Key: "_id", Value: 1}, {Key: "code", Value: "a"}, {Key: "qty", Value: 2}})
+	target, _ := bson.Marshal(bson.D{{Key: "_id", Value: 9}, {Key: "code", Value: "a"}, {Key: "qty", Value: 2}})
+
+	if same, err := sameDocument(source, target, false); err != nil || same {
+		t.Errorf("expected documents with different _id to differ, got %v, %v", same, err)
+	}
+	if same, err := sameDocument(source, target, true); err != nil || !same {
+		t.Errorf("expected documents to match without _id, got %v, %v", same, err)
+	}
+
+	changed, _ := bson.Marshal(bson.D{{Key: "_id", Value: 9}, {Key: "code", Value: "a"}, {Key: "qty", Value: 3}})
+	if same, _ := sameDocument(source, changed, true); same {
+		t.Error("expected changed field to differ")
+	}
+}
+
+func TestSamplePipeline_Limited(t *testing.T) {
+	query, err := copyQuery{filter: `{"tenant":"acme"}`, sort: `{"n":-1}`, limit: 5}.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline := samplePipeline(query, 100)
+	var stages []string
+	for _, stage := range pipeline {
+		stages = append(stages, stage[0].Key)
+	}
+	if got := strings.Join(stages, " "); got != "$match $sort $limit $sample" {
+		t.Errorf("expected the copied documents to be chosen before sampling, got %s", got)
+	}
+}
+
+func TestVerifyCopy_Limited(t *testing.T) {
+	client, uri := replicaSetClient(t)
+	db := client.Database("mongo_move_txn")
+	seedCollection(t, db.Collection("source"), 50, "n")
+	db.Collection("target").Drop(context.Background())
+
+	s := newStorage(uri, uri).withVerify(50, "")
+	spec := copySpec{sourceDatabase: "mongo_move_txn", sourceCollection: "source", targetDatabase: "mongo_move_txn", targetCollection: "target", query: copyQuery{sort: `{"n":-1}`, limit: 5}}
+	if _, err := s.copy(context.Background(), spec); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.verifyCopy(context.Background(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.ok() || report.Sampled != 5 {
+		t.Errorf("expected limited copy to verify with 5 samples, got %+v", report)
+	}
+}
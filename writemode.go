@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// How documents are written to the target collection
+type writeMode int
+
+const (
+	modeReplace       writeMode = iota // Delete every target document then insert the source documents
+	modeAppend                         // Insert the source documents alongside existing target documents
+	modeUpsert                         // Replace target documents matching on key, inserting the rest
+	modeInsertMissing                  // Insert only source documents whose key is not in the target
+)
+
+const defaultWriteKey = "_id" // Field used to match documents when no key is chosen
+
+var writeModes = []writeMode{modeReplace, modeAppend, modeUpsert, modeInsertMissing}
+
+func (w writeMode) String() string {
+	switch w {
+	case modeReplace:
+		return "replace"
+	case modeAppend:
+		return "append"
+	case modeUpsert:
+		return "upsert"
+	case modeInsertMissing:
+		return "insert-missing"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse write mode from its name, an empty name is the default replace mode
+func parseWriteMode(name string) (writeMode, error) {
+	if name == "" {
+		return modeReplace, nil
+	}
+	for _, w := range writeModes {
+		if w.String() == name {
+			return w, nil
+		}
+	}
+
+	return modeReplace, fmt.Errorf("unknown write mode %q", name)
+}
+
+// Next write mode, wrapping around after the last one
+func (w writeMode) next() writeMode {
+	return writeModes[(int(w)+1)%len(writeModes)]
+}
+
+// Check if the mode matches documents on a key field
+func (w writeMode) keyed() bool {
+	return w == modeUpsert || w == modeInsertMissing
+}
+
+// Check if the mode deletes target documents before writing
+func (w writeMode) destructive() bool {
+	return w == modeReplace
+}
+
+// Build the bulk write model that writes doc to the target collection.
+// raw is the encoded document, used to read the key field for keyed modes. When matching on a
+// key other than _id the source _id is left out so matched target documents keep their own _id.
+func (w writeMode) writeModel(doc bson.D, raw bson.Raw, key string) (mongo.WriteModel, error) {
+	if !w.keyed() {
+		return mongo.NewInsertOneModel().SetDocument(doc), nil
+	}
+
+	if key == "" {
+		key = defaultWriteKey
+	}
+	value, err := raw.LookupErr(strings.Split(key, ".")...)
+	if err != nil {
+		return nil, fmt.Errorf("document is missing key field %q", key)
+	}
+	filter := bson.D{{Key: key, Value: value}}
+
+	if key != defaultWriteKey {
+		doc = withoutField(doc, defaultWriteKey)
+	}
+
+	if w == modeUpsert {
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true), nil
+	}
+
+	return mongo.NewUpdateOneModel().
+		SetFilter(filter).
+		SetUpdate(bson.D{{Key: "$setOnInsert", Value: doc}}).
+		SetUpsert(true), nil
+}
+
+// Copy of doc without the top level field name
+func withoutField(doc bson.D, name string) bson.D {
+	out := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key != name {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
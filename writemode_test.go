@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestParseWriteMode(t *testing.T) {
+	for _, w := range writeModes {
+		got, err := parseWriteMode(w.String())
+		if err != nil || got != w {
+			t.Errorf("expected %s, got %s %v", w, got, err)
+		}
+	}
+
+	if got, err := parseWriteMode(""); err != nil || got != modeReplace {
+		t.Errorf("expected empty name to be replace, got %s %v", got, err)
+	}
+	if _, err := parseWriteMode("merge"); err == nil {
+		t.Error("expected error for unknown write mode")
+	}
+}
+
+func TestWriteMode_Next(t *testing.T) {
+	if modeReplace.next() != modeAppend {
+		t.Errorf("expected append after replace, got %s", modeReplace.next())
+	}
+	if modeInsertMissing.next() != modeReplace {
+		t.Errorf("expected replace after insert-missing, got %s", modeInsertMissing.next())
+	}
+}
+
+func TestWriteMode_WriteModel(t *testing.T) {
+	doc := bson.D{{Key: "_id", Value: 1}, {Key: "code", Value: "GB"}}
+	raw, _ := bson.Marshal(doc)
+
+	if wm, _ := modeAppend.writeModel(doc, raw, ""); wm == nil {
+		t.Error("expected insert model for append")
+	} else if _, ok := wm.(*mongo.InsertOneModel); !ok {
+		t.Errorf("expected insert model for append, got %T", wm)
+	}
+
+	wm, err := modeUpsert.writeModel(doc, raw, "code")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	replace, ok := wm.(*mongo.ReplaceOneModel)
+	if !ok {
+		t.Fatalf("expected replace model for upsert, got %T", wm)
+	}
+	if replacement := replace.Replacement.(bson.D); len(replacement) != 1 || replacement[0].Key != "code" {
+		t.Errorf("expected _id to be left out when matching on another key, got %v", replacement)
+	}
+
+	wm, err = modeInsertMissing.writeModel(doc, raw, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := wm.(*mongo.UpdateOneModel); !ok {
+		t.Errorf("expected update model for insert-missing, got %T", wm)
+	}
+}
+
+func TestWriteMode_WriteModelMissingKey(t *testing.T) {
+	doc := bson.D{{Key: "_id", Value: 1}}
+	raw, _ := bson.Marshal(doc)
+
+	if _, err := modeUpsert.writeModel(doc, raw, "code"); err == nil {
+		t.Error("expected error for document missing key field")
+	}
+}